@@ -24,7 +24,8 @@ func runBuild(cmd *cobra.Command, args []string) error {
 }
 
 func build(clusterName string, timeout int) error {
-	outback := Outback.New(awsConfig)
+	outback := Outback.New(cfg.getAwsConfig(clusterName))
+	repo := cfg.getRepo(clusterName)
 
 	commit, err := git.GetCommit()
 	if err != nil {
@@ -40,7 +41,7 @@ func build(clusterName string, timeout int) error {
 
 	deployment := &Outback.Deployment{}
 	deployment.SetCommitHash(commit)
-	deployment.SetRepo(cfg.Repo)
+	deployment.SetRepo(repo)
 	deployment.SetDockerfile(cluster.Dockerfile)
 	deployment.SetBuildArgs(buildArgs)
 	deployment.SetConfigBuildArgs(configBuildArgs)
@@ -53,7 +54,7 @@ func build(clusterName string, timeout int) error {
 		return err
 	}
 
-	fmt.Printf("Successfully built and pushed image to repository:\n\t%s:%s\n", cfg.Repo, commit)
+	fmt.Printf("Successfully built and pushed image to repository:\n\t%s:%s\n", repo, commit)
 
 	return nil
 }
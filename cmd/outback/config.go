@@ -6,26 +6,45 @@ import (
 	"log"
 	"os"
 	"strings"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
 )
 
 type Config struct {
-	Profile  string     `mapstructure:"profile"`
-	Region   string     `mapstructure:"region"`
-	Repo     string     `mapstructure:"repo"`
-	Clusters []*Cluster `mapstructure:"clusters"`
-	Tasks    []*Task    `mapstructure:"tasks"`
+	Profile       string     `mapstructure:"profile"`
+	Region        string     `mapstructure:"region"`
+	Repo          string     `mapstructure:"repo"`
+	Clusters      []*Cluster `mapstructure:"clusters"`
+	Tasks         []*Task    `mapstructure:"tasks"`
+	SensitiveKeys []string   `mapstructure:"sensitive-keys"`
+	EnvFileBucket string     `mapstructure:"env-file-bucket"`
+	ScheduleRole  string     `mapstructure:"schedule-role"`
 }
 
 type Cluster struct {
-	Name       string   `mapstructure:"name"`
-	Services   []string `mapstructure:"services"`
-	Dockerfile string   `mapstructure:"dockerfile"`
-	BuildArgs  []string `mapstructure:"build-args"`
+	Name          string   `mapstructure:"name"`
+	Services      []string `mapstructure:"services"`
+	Dockerfile    string   `mapstructure:"dockerfile"`
+	BuildArgs     []string `mapstructure:"build-args"`
+	Repo          string   `mapstructure:"repo"`
+	Profile       string   `mapstructure:"profile"`
+	Region        string   `mapstructure:"region"`
+	EnvFileBucket string   `mapstructure:"env-file-bucket"`
+	PreDeploy     []string `mapstructure:"pre-deploy"`
+	PostDeploy    []string `mapstructure:"post-deploy"`
+	ScheduleRole  string   `mapstructure:"schedule-role"`
 }
 
 type Task struct {
-	Name    string `mapstructure:"name"`
-	Command string `mapstructure:"command"`
+	Name       string   `mapstructure:"name"`
+	Command    string   `mapstructure:"command"`
+	Entrypoint string   `mapstructure:"entrypoint"`
+	Container  string   `mapstructure:"container"`
+	Env        []string `mapstructure:"env"`
+	Cpu        string   `mapstructure:"cpu"`
+	Memory     string   `mapstructure:"memory"`
+	Service    string   `mapstructure:"service"`
+	Schedule   string   `mapstructure:"schedule"`
 }
 
 func (c *Config) getConfigs() []string {
@@ -80,10 +99,10 @@ func (c *Config) getService(services []string, service string) (*string, error)
 	return nil, ErrServiceNotFound
 }
 
-func (c *Config) getCommand(name string) (*string, error) {
+func (c *Config) getTask(name string) (*Task, error) {
 	for _, t := range c.Tasks {
 		if t.Name == name {
-			return &t.Command, nil
+			return t, nil
 		}
 	}
 
@@ -98,3 +117,69 @@ func (c *Config) getBuildArgs(in string) []string {
 	}
 	return []string{}
 }
+
+// getRepo returns the repo of a cluster, falling back to the top level repo
+func (c *Config) getRepo(in string) string {
+	for _, cluster := range c.Clusters {
+		if cluster.Name == in && cluster.Repo != "" {
+			return cluster.Repo
+		}
+	}
+	return c.Repo
+}
+
+// getEnvFileBucket returns the environment file bucket of a cluster, falling back to the top
+// level bucket
+func (c *Config) getEnvFileBucket(in string) string {
+	for _, cluster := range c.Clusters {
+		if cluster.Name == in && cluster.EnvFileBucket != "" {
+			return cluster.EnvFileBucket
+		}
+	}
+	return c.EnvFileBucket
+}
+
+// getScheduleRole returns the role EventBridge assumes to run the scheduled tasks of a cluster,
+// falling back to the top level role
+func (c *Config) getScheduleRole(in string) string {
+	for _, cluster := range c.Clusters {
+		if cluster.Name == in && cluster.ScheduleRole != "" {
+			return cluster.ScheduleRole
+		}
+	}
+	return c.ScheduleRole
+}
+
+// getAwsConfig returns the AWS profile and region of a cluster, falling back to the top
+// level profile and region for any that the cluster does not set
+func (c *Config) getAwsConfig(in string) *Outback.AwsConfig {
+	awsConfig := &Outback.AwsConfig{
+		Profile: c.Profile,
+		Region:  c.Region,
+	}
+
+	for _, cluster := range c.Clusters {
+		if cluster.Name != in {
+			continue
+		}
+
+		if cluster.Profile != "" {
+			awsConfig.Profile = cluster.Profile
+		}
+
+		if cluster.Region != "" {
+			awsConfig.Region = cluster.Region
+		}
+	}
+
+	return awsConfig
+}
+
+// getSensitiveKeys returns the configured sensitive key patterns or the default patterns
+func (c *Config) getSensitiveKeys() []string {
+	if len(c.SensitiveKeys) > 0 {
+		return c.SensitiveKeys
+	}
+
+	return Outback.DefaultSensitiveEnvPatterns
+}
@@ -4,20 +4,37 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/koala-labs/outback/pkg/git"
 	Outback "github.com/koala-labs/outback/pkg/outback"
 	"github.com/koala-labs/outback/pkg/term"
 	"github.com/spf13/cobra"
 )
 
-var deployBuildArgs []string
+var (
+	deployBuildArgs        []string
+	flagDeployAutoRollback bool
+	flagDeployTag          string
+	flagDeployImage        string
+)
 
 var deployCmd = &cobra.Command{
 	Use:   "deploy",
 	Short: "Create a deployment",
 	Long: `A cluster must be specified via the --cluster flag.
 	The --verbose flag can be input to enable verbose output.
-	The --login flag can be input to login to AWS ECR.`,
+	The --login flag can be input to login to AWS ECR.
+	The --auto-rollback flag can be input to point every service back to its previous
+	task definition if the deployment fails or times out.
+	The --dry-run flag can be input to print the changes without building or deploying.
+	The --tag or --image flag can be input to deploy an image that was already pushed to
+	the configured repo instead of building one from the current commit.
+	The pre-deploy tasks of the cluster in the config run with the newly registered task
+	definition after the image is pushed and before any service is updated. The deployment
+	is abandoned if one of them fails. The post-deploy tasks run once every service is running,
+	a failing post-deploy task fails the deployment and is rolled back with --auto-rollback.
+	Scheduled tasks of the cluster are pointed to the new task definition once the deployment
+	succeeded.`,
 	RunE: runDeploy,
 }
 
@@ -26,23 +43,40 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 }
 
 func deploy(clusterName string, timeout int) error {
-	outback := Outback.New(awsConfig)
+	outback := Outback.New(cfg.getAwsConfig(clusterName))
+	repo := cfg.getRepo(clusterName)
 
-	commit, err := git.GetCommit()
+	commit, prebuilt, err := deployTag(outback, repo)
 	if err != nil {
 		return err
 	}
 
+	return deployCommit(outback, clusterName, repo, commit, prebuilt, timeout)
+}
+
+// deployCommit deploys the image tagged with commit to every service of a cluster, building
+// and pushing the image first unless it was prebuilt
+func deployCommit(outback *Outback.Outback, clusterName string, repo string, commit string, prebuilt bool, timeout int) error {
 	cluster, err := cfg.getCluster(clusterName)
 	if err != nil {
 		return err
 	}
 
+	preDeploy, err := deployTasks(cluster, cluster.PreDeploy)
+	if err != nil {
+		return err
+	}
+
+	postDeploy, err := deployTasks(cluster, cluster.PostDeploy)
+	if err != nil {
+		return err
+	}
+
 	configBuildArgs := cfg.getBuildArgs(clusterName)
 
 	deployment := &Outback.Deployment{}
 	deployment.SetCommitHash(commit)
-	deployment.SetRepo(cfg.Repo)
+	deployment.SetRepo(repo)
 	deployment.SetDockerfile(cluster.Dockerfile)
 	deployment.SetBuildArgs(deployBuildArgs)
 	deployment.SetConfigBuildArgs(configBuildArgs)
@@ -74,12 +108,13 @@ func deploy(clusterName string, timeout int) error {
 			return err
 		}
 
-		// Set the TaskDefinition in the deployment detail
+		// Set the TaskDefinition in the deployment detail and remember it in case we need to roll back
 		detail.SetTaskDefinition(ecsTaskDef)
+		detail.SetPreviousTaskDefinition(ecsTaskDef)
 
 		// Get the commit from the last TaskDefinition if it exists
 		commit, err := outback.GetLastDeployedCommit(*ecsTaskDef.TaskDefinitionArn)
-		if err == nil {
+		if err == nil && !prebuilt {
 			deployment.SetBuildCacheFrom([]string{fmt.Sprintf("%s:%s", deployment.BuildDetail.Repo, commit)})
 			fmt.Printf("Will attempt to restore Docker cache for %s from commit: %s\n", service, commit)
 		}
@@ -87,19 +122,38 @@ func deploy(clusterName string, timeout int) error {
 		deployment.DeployDetails = append(deployment.DeployDetails, detail)
 	}
 
-	// Build Docker image and push to repo
-	err = outback.LoginBuildPushImage(deployment.BuildDetail)
-	if err != nil {
-		return err
+	if flagDryRun {
+		printDeployTasks("Pre-deploy", preDeploy)
+		printDeployTasks("Post-deploy", postDeploy)
+		return planDeploy(outback, deployment)
+	}
+
+	if prebuilt {
+		fmt.Printf("Deploying existing image %s:%s\n", deployment.BuildDetail.Repo, commit)
+	} else {
+		// Build Docker image and push to repo
+		err = outback.LoginBuildPushImage(deployment.BuildDetail)
+		if err != nil {
+			return err
+		}
 	}
 
 	term.Clear()
 
-	errCh := outback.DeployAll(deployment)
+	errCh := outback.RegisterAll(deployment)
 
 	for err := range errCh {
-		fmt.Printf("Deployment failed: %s \n", err)
-		return err
+		return abandonDeploy(outback, deployment, err)
+	}
+
+	if err := runDeployTasks(outback, deployment, preDeploy, timeout); err != nil {
+		return abandonDeploy(outback, deployment, fmt.Errorf("%w: %s", ErrPreDeployFailed, err))
+	}
+
+	errCh = outback.UpdateAll(deployment)
+
+	for err := range errCh {
+		return autoRollback(outback, deployment, timeout, err)
 	}
 
 	fmt.Printf("Waiting for deployment(s) to services [ %s]\n", deployment.Services())
@@ -110,14 +164,243 @@ func deploy(clusterName string, timeout int) error {
 		case detail := <-doneCh:
 			fmt.Printf("Service %s (%s) is now running \n", *detail.Service.ServiceName, detail.TaskDefinitionFamily())
 		case <-time.After(time.Minute * time.Duration(timeout)):
-			return ErrDeployTimeout
+			return autoRollback(outback, deployment, timeout, ErrDeployTimeout)
+		}
+	}
+
+	if err := runDeployTasks(outback, deployment, postDeploy, timeout); err != nil {
+		return autoRollback(outback, deployment, timeout, fmt.Errorf("%w: %s", ErrPostDeployFailed, err))
+	}
+
+	return repointSchedules(outback, cluster, deployment)
+}
+
+// repointSchedules points the scheduled tasks of a cluster to the task definitions the
+// deployment registered, so they do not keep running the code of the previous deploy
+func repointSchedules(outback *Outback.Outback, cluster *Cluster, deployment *Outback.Deployment) error {
+	if len(deployment.DeployDetails) == 0 {
+		return nil
+	}
+
+	schedules, err := outback.ListSchedules(deployment.DeployDetails[0].Cluster)
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		for _, task := range cfg.Tasks {
+			if schedule.Rule != Outback.ScheduleRuleName(cluster.Name, task.Name) {
+				continue
+			}
+
+			detail, err := deployTaskDetail(deployment, task)
+			if err != nil {
+				return err
+			}
+
+			overrides, err := taskAliasOverrides(task)
+			if err != nil {
+				return err
+			}
+
+			t, err := outback.RunTaskDefinition(detail.TaskDefinition, overrides)
+			if err != nil {
+				return err
+			}
+
+			previous := schedule.TaskDefinition()
+
+			if err := outback.RepointSchedule(schedule, t); err != nil {
+				return err
+			}
+
+			if previous != aws.StringValue(t.TaskDefinitionArn) {
+				releaseRunTaskDefinition(outback, detail.TaskDefinition, previous)
+			}
+
+			fmt.Printf("Scheduled task %s now runs %s\n", task.Name, taskID(aws.StringValue(t.TaskDefinitionArn)))
+		}
+	}
+
+	return nil
+}
+
+// deployTasks returns the task aliases a cluster runs before or after a deployment. Every
+// alias is checked before anything is built so a typo does not abandon a deployment halfway.
+func deployTasks(cluster *Cluster, names []string) ([]*Task, error) {
+	tasks := make([]*Task, len(names))
+
+	for i, name := range names {
+		task, err := cfg.getTask(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s'", err, name)
+		}
+
+		if task.Service != "" {
+			if _, err := cfg.getService(cluster.Services, task.Service); err != nil {
+				return nil, fmt.Errorf("%w: '%s'", err, task.Service)
+			}
+		}
+
+		tasks[i] = task
+	}
+
+	return tasks, nil
+}
+
+// printDeployTasks prints the task aliases a deployment would run
+func printDeployTasks(stage string, tasks []*Task) {
+	for _, task := range tasks {
+		fmt.Printf("%s task %s: %s\n", stage, task.Name, task.Command)
+	}
+}
+
+// runDeployTasks runs task aliases one after another with the task definition the deployment
+// registered for the service of the alias, or for the first service when the alias has none.
+// It stops at the first task that fails or does not stop within timeout minutes.
+func runDeployTasks(outback *Outback.Outback, deployment *Outback.Deployment, tasks []*Task, timeout int) error {
+	for _, task := range tasks {
+		detail, err := deployTaskDetail(deployment, task)
+		if err != nil {
+			return err
+		}
+
+		overrides, err := taskAliasOverrides(task)
+		if err != nil {
+			return err
+		}
+
+		service := *detail.Service.ServiceName
+		fmt.Printf("Running task %s with %s\n", task.Name, detail.TaskDefinitionFamily())
+
+		err = runAndWait(outback, detail.Cluster, detail.TaskDefinition, overrides, Outback.ServiceTaskLaunch(detail.Service), service, timeout)
+		if err != nil {
+			return fmt.Errorf("'%s': %s", task.Name, err)
 		}
 	}
 
 	return nil
 }
 
+// deployTaskDetail returns the deploy detail of the service a task alias runs as
+func deployTaskDetail(deployment *Outback.Deployment, task *Task) (*Outback.DeployDetail, error) {
+	if task.Service == "" && len(deployment.DeployDetails) > 0 {
+		return deployment.DeployDetails[0], nil
+	}
+
+	for _, detail := range deployment.DeployDetails {
+		if *detail.Service.ServiceName == task.Service {
+			return detail, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: '%s'", ErrServiceNotFound, task.Service)
+}
+
+// abandonDeploy deregisters the task definitions registered for a deployment that failed
+// before any service was updated, so they are never picked as a rollback target
+func abandonDeploy(outback *Outback.Outback, deployment *Outback.Deployment, deployErr error) error {
+	fmt.Printf("Deployment failed: %s \n", deployErr)
+
+	for err := range outback.DeregisterAll(deployment) {
+		fmt.Printf("Could not deregister task definition: %s \n", err)
+	}
+
+	return deployErr
+}
+
+// deployTag returns the image tag to deploy and whether that image was already pushed.
+// Without --tag or --image the current git commit is built and deployed.
+func deployTag(outback *Outback.Outback, repo string) (string, bool, error) {
+	tag := flagDeployTag
+
+	if flagDeployTag != "" && flagDeployImage != "" {
+		return "", false, ErrTagAndImage
+	}
+
+	if flagDeployImage != "" {
+		imageRepo, imageTag, err := Outback.ParseImage(flagDeployImage)
+		if err != nil {
+			return "", false, err
+		}
+
+		if imageRepo != repo {
+			return "", false, ErrImageNotInRepo
+		}
+
+		tag = imageTag
+	}
+
+	if tag == "" {
+		commit, err := git.GetCommit()
+		return commit, false, err
+	}
+
+	if _, err := outback.GetImage(repo, tag); err != nil {
+		return "", false, err
+	}
+
+	return tag, true, nil
+}
+
+// planDeploy prints the task definition every service would be updated to
+func planDeploy(outback *Outback.Outback, deployment *Outback.Deployment) error {
+	for _, detail := range deployment.DeployDetails {
+		plan, err := outback.PlanImage(detail.Cluster, detail.Service, deployment.BuildDetail.Repo, deployment.BuildDetail.CommitHash)
+		if err != nil {
+			return err
+		}
+
+		printPlan(plan)
+	}
+
+	return nil
+}
+
+// autoRollback reports a deployment that failed after its services were updated and reverts
+// every service it touched when --auto-rollback is set. The original deployment error is
+// always returned, with the rollback error added when the rollback itself fails.
+func autoRollback(outback *Outback.Outback, deployment *Outback.Deployment, timeout int, deployErr error) error {
+	fmt.Printf("Deployment failed: %s \n", deployErr)
+
+	if !flagDeployAutoRollback {
+		return deployErr
+	}
+
+	fmt.Println("Rolling back services to their previous task definitions")
+
+	reverted, errCh := outback.RevertAll(deployment)
+
+	for err := range errCh {
+		fmt.Printf("Rollback failed: %s \n", err)
+		return fmt.Errorf("%w, rollback failed: %s", deployErr, err)
+	}
+
+	if len(reverted.DeployDetails) == 0 {
+		fmt.Println("No services were updated, nothing to roll back")
+		return deployErr
+	}
+
+	fmt.Printf("Waiting for rollback of services [ %s]\n", reverted.Services())
+	doneCh := outback.AwaitServicesRunning(reverted)
+
+	for i := 0; i < len(reverted.DeployDetails); i++ {
+		select {
+		case detail := <-doneCh:
+			fmt.Printf("Service %s reverted to %s \n", *detail.Service.ServiceName, detail.TaskDefinitionFamily())
+		case <-time.After(time.Minute * time.Duration(timeout)):
+			return fmt.Errorf("%w, rollback failed: %s", deployErr, ErrRollbackTimeout)
+		}
+	}
+
+	return deployErr
+}
+
 func init() {
 	rootCmd.AddCommand(deployCmd)
 	deployCmd.Flags().StringSliceVarP(&deployBuildArgs, "build-arg", "b", []string{}, "Set build-time variables")
+	deployCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without deploying")
+	deployCmd.Flags().StringVar(&flagDeployTag, "tag", "", "Deploy an existing image tag from the configured repo without building")
+	deployCmd.Flags().StringVar(&flagDeployImage, "image", "", "Deploy an existing image URI (repo:tag) without building")
+	deployCmd.Flags().BoolVar(&flagDeployAutoRollback, "auto-rollback", false, "Roll services back to their previous task definition if the deployment fails")
 }
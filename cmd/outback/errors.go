@@ -15,7 +15,16 @@ var (
 
 // Deploy Errors
 var (
-	ErrDeployTimeout = errors.New("Timed out waiting for task to start")
+	ErrDeployTimeout            = errors.New("Timed out waiting for task to start")
+	ErrRollbackTimeout          = errors.New("Timed out waiting for rolled back task to start")
+	ErrImageNotInRepo           = errors.New("The image must belong to the repo in your config")
+	ErrTagAndImage              = errors.New("Only one of --tag and --image can be given")
+	ErrPromoteMultipleTags      = errors.New("The services in the source cluster are running different image tags")
+	ErrCommitAndRevision        = errors.New("Only one of --commit and --revision can be given")
+	ErrRevisionMultipleFamilies = errors.New("The services use different task definition families, use --service to roll back one service to a revision")
+	ErrNoRollbackRevisions      = errors.New("The service has no earlier revisions to roll back to")
+	ErrPreDeployFailed          = errors.New("A pre-deploy task failed, no service was updated")
+	ErrPostDeployFailed         = errors.New("A post-deploy task failed")
 )
 
 // Init errors
@@ -26,10 +35,33 @@ var (
 
 // Service errors
 var (
-	ErrInvalidEnvInput       = errors.New("Input must be in the form of key=value")
-	ErrKeyNotPresent         = errors.New("The key entered was not present in the environment variables for this service")
-	ErrCouldNotParseTime     = errors.New("Could not parse the given time")
-	ErrCantFollowWithEndTime = errors.New("Could not follow logs because an end time was given")
+	ErrInvalidEnvInput        = errors.New("Input must be in the form of key=value")
+	ErrInvalidEnvName         = errors.New("Keys may only contain letters, digits and underscores and must not start with a digit")
+	ErrDuplicateEnvName       = errors.New("The key was given more than once")
+	ErrKeyNotPresent          = errors.New("The key entered was not present in the environment variables for this service")
+	ErrCouldNotParseTime      = errors.New("Could not parse the given time")
+	ErrCantFollowWithEndTime  = errors.New("Could not follow logs because an end time was given")
+	ErrInvalidOutput          = errors.New("Unsupported output format")
+	ErrInvalidSecretStore     = errors.New("The secret store must be ssm or secretsmanager")
+	ErrDiffClusterAndRevision = errors.New("Only one of --to-cluster/--to-service and --from-revision/--to-revision can be given")
+	ErrDiffNothingToCompare   = errors.New("Either --to-cluster/--to-service or --from-revision/--to-revision must be given")
+	ErrNoEnvFileBucket        = errors.New("No env-file-bucket is configured for this cluster")
+	ErrEnvFileNotAttached     = errors.New("The environment file is not attached to this service")
+	ErrScaleNothingToChange   = errors.New("At least one of --count, --min and --max must be given")
+	ErrInvalidScaleCount      = errors.New("The count must not be negative")
+	ErrScaleTimeout           = errors.New("Timed out waiting for the service to reach the desired count")
+	ErrRestartTimeout         = errors.New("Timed out waiting for the old tasks to be replaced")
+)
+
+// Task errors
+var (
+	ErrLaunchTypeAndCapacityProvider = errors.New("Only one of --launch-type and --capacity-provider can be given")
+	ErrTaskNotFound                  = errors.New("The stopped task could not be found")
+	ErrTaskTimeout                   = errors.New("Timed out waiting for the task to stop")
+	ErrNoScheduleRole                = errors.New("No schedule-role is configured for this cluster")
+	ErrNoSchedule                    = errors.New("No schedule was given, use --schedule or set the schedule of the task in your config")
+	ErrScheduleExists                = errors.New("The task is already scheduled on this cluster, use update to change it")
+	ErrScheduleNotFound              = errors.New("The task is not scheduled on this cluster")
 )
 
 // handleError is intended to be called with an error return to simplify error handling
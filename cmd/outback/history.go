@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"strconv"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var flagHistoryLimit int
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past deployments of a service",
+	Long: `A cluster and service must be specified via the --cluster and --service flags.
+	Lists the revisions of the service's task definition family, newest first, with the deploy
+	time and git commit recorded by outback deploy, the image tag and which revision the
+	service is currently running.
+	The --limit flag can be input to limit the number of revisions listed.
+	The --output flag can be input to print the history as json.`,
+	RunE: runHistory,
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	if err := validateOutput(flagOutput, outputTable, outputJSON); err != nil {
+		return err
+	}
+
+	cfgCluster, err := cfg.getCluster(flagCluster)
+	if err != nil {
+		return err
+	}
+
+	cfgService, err := cfg.getService(cfgCluster.Services, flagService)
+	if err != nil {
+		return err
+	}
+
+	outback := Outback.New(awsConfig)
+
+	c, err := outback.GetCluster(cfgCluster.Name)
+	if err != nil {
+		return err
+	}
+
+	s, err := outback.GetService(c, *cfgService)
+	if err != nil {
+		return err
+	}
+
+	history, err := outback.GetRevisionHistory(s, flagHistoryLimit)
+	if err != nil {
+		return err
+	}
+
+	if flagOutput == outputJSON {
+		return printJSON(history)
+	}
+
+	printHistoryTable(history)
+
+	return nil
+}
+
+func printHistoryTable(history []*Outback.RevisionDetail) {
+	rows := make([][]string, len(history))
+
+	for i, revision := range history {
+		current := ""
+		if revision.Current {
+			current = "*"
+		}
+
+		rows[i] = []string{
+			strconv.FormatInt(revision.Revision, 10),
+			current,
+			revision.DeployTime,
+			revision.DeployCommit,
+			revision.ImageTag,
+		}
+	}
+
+	printTable([]string{"Revision", "Current", "Deployed At", "Commit", "Image Tag"}, rows)
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().IntVar(&flagHistoryLimit, "limit", 10, "Maximum number of revisions to list, 0 lists all")
+	historyCmd.Flags().StringVarP(&flagOutput, "output", "o", outputTable, "Output format (table or json)")
+}
@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	survey "gopkg.in/AlecAivazis/survey.v1"
@@ -12,7 +16,74 @@ var interactiveCmd = &cobra.Command{
 	RunE:  interactiveDeploy,
 }
 
+var interactiveRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Interactively roll back a service by selecting from its recent task definition revisions",
+	RunE:  interactiveRollback,
+}
+
 func interactiveDeploy(cmd *cobra.Command, args []string) error {
+	cluster, err := askCluster()
+	if err != nil {
+		return err
+	}
+
+	confirmed, err := askConfirm("Are you sure you want to deploy?")
+	if err != nil {
+		return err
+	}
+
+	if confirmed {
+		return deploy(cluster, 5)
+	}
+
+	return nil
+}
+
+func interactiveRollback(cmd *cobra.Command, args []string) error {
+	cluster, err := askCluster()
+	if err != nil {
+		return err
+	}
+
+	var serviceQuestion = []*survey.Question{
+		{
+			Name: "service",
+			Prompt: &survey.Select{
+				Message: "Choose a service:",
+				Options: cfg.getServices(cluster),
+			},
+		},
+	}
+
+	serviceAnswer := struct {
+		Service string `survey:"service"`
+	}{}
+
+	err = survey.Ask(serviceQuestion, &serviceAnswer)
+	if err != nil {
+		return err
+	}
+
+	revision, err := askRevision(cluster, serviceAnswer.Service)
+	if err != nil {
+		return err
+	}
+
+	confirmed, err := askConfirm(fmt.Sprintf("Are you sure you want to roll %s back to revision %d?", serviceAnswer.Service, revision))
+	if err != nil {
+		return err
+	}
+
+	if confirmed {
+		return rollback(cluster, serviceAnswer.Service, revision, 5)
+	}
+
+	return nil
+}
+
+// askCluster asks for a config and a cluster in that config and loads the chosen config
+func askCluster() (string, error) {
 	var configQuestion = []*survey.Question{
 		{
 			Name: "config",
@@ -29,7 +100,7 @@ func interactiveDeploy(cmd *cobra.Command, args []string) error {
 
 	err := survey.Ask(configQuestion, &configAnswer)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	viper.SetConfigName(configAnswer.Config)
@@ -48,38 +119,117 @@ func interactiveDeploy(cmd *cobra.Command, args []string) error {
 
 	clusterAnswer := struct {
 		Cluster string `survey:"cluster"`
-		Login   string `survey:"login"`
 	}{}
 
 	err = survey.Ask(clusterQuestion, &clusterAnswer)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	return clusterAnswer.Cluster, nil
+}
+
+// askRevision lists the recent revisions of a service's task definition family, other than
+// the one it is running, annotated with their deploy markers and image, and returns the
+// revision picked
+func askRevision(clusterName string, serviceName string) (int, error) {
+	cluster, err := cfg.getCluster(clusterName)
+	if err != nil {
+		return 0, err
+	}
+
+	outback := Outback.New(cfg.getAwsConfig(clusterName))
+
+	c, err := outback.GetCluster(cluster.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := outback.GetService(c, serviceName)
+	if err != nil {
+		return 0, err
+	}
+
+	history, err := outback.GetRevisionHistory(s, 10)
+	if err != nil {
+		return 0, err
+	}
+
+	options := make([]string, 0, len(history))
+	revisions := map[string]int{}
+
+	for _, revision := range history {
+		if revision.Current {
+			continue
+		}
+
+		option := revisionOption(revision)
+		options = append(options, option)
+		revisions[option] = int(revision.Revision)
+	}
+
+	if len(options) == 0 {
+		return 0, ErrNoRollbackRevisions
+	}
+
+	var revisionQuestion = []*survey.Question{
+		{
+			Name: "revision",
+			Prompt: &survey.Select{
+				Message: "Choose a revision to roll back to:",
+				Options: options,
+			},
+		},
+	}
+
+	revisionAnswer := struct {
+		Revision string `survey:"revision"`
+	}{}
+
+	err = survey.Ask(revisionQuestion, &revisionAnswer)
+	if err != nil {
+		return 0, err
 	}
 
+	return revisions[revisionAnswer.Revision], nil
+}
+
+// revisionOption describes a revision as its number, deploy time, git commit and image
+func revisionOption(revision *Outback.RevisionDetail) string {
+	deployTime, commit, image := revision.DeployTime, revision.DeployCommit, revision.Image
+
+	if deployTime == "" {
+		deployTime = "not deployed by outback"
+	}
+
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%d  %s  %s  %s", revision.Revision, deployTime, commit, image))
+}
+
+func askConfirm(message string) (bool, error) {
 	var confirmQuestion = []*survey.Question{
 		{
 			Name: "confirm",
 			Prompt: &survey.Select{
-				Message: "Are you sure you want to deploy?",
+				Message: message,
 				Options: []string{"no", "yes"},
 			},
 		},
 	}
 
 	confirmAnswer := struct {
-		Confirm string `suvery:"confirm"`
+		Confirm string `survey:"confirm"`
 	}{}
 
-	err = survey.Ask(confirmQuestion, &confirmAnswer)
+	err := survey.Ask(confirmQuestion, &confirmAnswer)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if toBool(confirmAnswer.Confirm) {
-		return deploy(clusterAnswer.Cluster, 5)
-	}
-
-	return nil
+	return toBool(confirmAnswer.Confirm), nil
 }
 
 func toBool(answer string) bool {
@@ -92,4 +242,5 @@ func toBool(answer string) bool {
 
 func init() {
 	rootCmd.AddCommand(interactiveCmd)
+	interactiveCmd.AddCommand(interactiveRollbackCmd)
 }
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+)
+
+// printPlan prints the changes a plan would make to a service. Values of sensitive keys are
+// masked like in env list unless --reveal was input.
+func printPlan(p *Outback.Plan) {
+	fmt.Printf("Plan for service %s on cluster %s\n", *p.Service.ServiceName, *p.Cluster.ClusterName)
+
+	changes := p.Changes()
+
+	if len(changes) == 0 {
+		fmt.Printf("  no changes\n\n")
+		return
+	}
+
+	patterns := cfg.getSensitiveKeys()
+	if flagReveal {
+		patterns = nil
+	}
+
+	for _, c := range changes {
+		c = c.Masked(patterns)
+
+		field := c.Field
+		if c.Container != "" {
+			field = fmt.Sprintf("%s %s", c.Container, c.Field)
+		}
+
+		switch c.Action {
+		case Outback.PlanActionAdd:
+			fmt.Printf("  + %s: %s\n", field, c.After)
+		case Outback.PlanActionRemove:
+			fmt.Printf("  - %s: %s\n", field, c.Before)
+		default:
+			fmt.Printf("  ~ %s: %s -> %s\n", field, c.Before, c.After)
+		}
+	}
+
+	fmt.Printf("\n")
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagPromoteFrom string
+	flagPromoteTo   string
+)
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Promote the image running in one cluster to another",
+	Long: `The source and destination clusters must be specified via the --from and --to flags.
+	The image tag running in the services of the source cluster is copied to the repo of the
+	destination cluster, which may live in another account or region, and then deployed to
+	every service of the destination cluster without building.
+	The --dry-run flag can be input to print the changes without copying or deploying.`,
+	RunE: runPromote,
+}
+
+func runPromote(cmd *cobra.Command, args []string) error {
+	return promote(flagPromoteFrom, flagPromoteTo, flagTimeout)
+}
+
+func promote(from string, to string, timeout int) error {
+	fromCluster, err := cfg.getCluster(from)
+	if err != nil {
+		return err
+	}
+
+	if _, err := cfg.getCluster(to); err != nil {
+		return err
+	}
+
+	source := Outback.New(cfg.getAwsConfig(from))
+	destination := Outback.New(cfg.getAwsConfig(to))
+	fromRepo := cfg.getRepo(from)
+	toRepo := cfg.getRepo(to)
+
+	tag, err := runningTag(source, fromCluster, fromRepo)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Promoting %s:%s from %s to %s:%s on %s\n", fromRepo, tag, from, toRepo, tag, to)
+
+	if !flagDryRun {
+		err = destination.PromoteImage(source, fromRepo, toRepo, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return deployCommit(destination, to, toRepo, tag, true, timeout)
+}
+
+// runningTag returns the image tag every service of a cluster is running and fails when the
+// services are running different tags
+func runningTag(outback *Outback.Outback, cluster *Cluster, repo string) (string, error) {
+	c, err := outback.GetCluster(cluster.Name)
+	if err != nil {
+		return "", err
+	}
+
+	var tag string
+
+	for _, service := range cluster.Services {
+		s, err := outback.GetService(c, service)
+		if err != nil {
+			return "", err
+		}
+
+		t, err := outback.GetTaskDefinition(c, s)
+		if err != nil {
+			return "", err
+		}
+
+		serviceTag, err := outback.GetImageTag(t, repo)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Printf("Service %s is running %s:%s\n", service, repo, serviceTag)
+
+		if tag != "" && tag != serviceTag {
+			return "", ErrPromoteMultipleTags
+		}
+
+		tag = serviceTag
+	}
+
+	if tag == "" {
+		return "", ErrServiceNotFound
+	}
+
+	return tag, nil
+}
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+	promoteCmd.Flags().StringVar(&flagPromoteFrom, "from", "", "Cluster to promote the running image from")
+	promoteCmd.Flags().StringVar(&flagPromoteTo, "to", "", "Cluster to deploy the promoted image to")
+	promoteCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without promoting")
+	promoteCmd.Flags().BoolVar(&flagDeployAutoRollback, "auto-rollback", false, "Roll services back to their previous task definition if the deployment fails")
+}
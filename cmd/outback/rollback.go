@@ -4,40 +4,66 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	Outback "github.com/koala-labs/outback/pkg/outback"
 	"github.com/koala-labs/outback/pkg/term"
 	"github.com/spf13/cobra"
 )
 
-var revisionNumber int
+var (
+	revisionNumber     int
+	flagRollbackCommit string
+)
 
 var rollbackCmd = &cobra.Command{
 	Use:   "rollback",
 	Short: "Rollback a deployment",
 	Long: `A cluster must be specified via the --cluster flag.
+	The --service flag can be input to roll back a single service of the cluster.
 	The --verbose flag can be input to enable verbose output.
-	The --login flag can be input to login to AWS ECR.`,
+	The --login flag can be input to login to AWS ECR.
+	Without --revision or --commit every service is rolled back to the previous deploy of its
+	own task definition family. Revisions of deployments ECS reports as failed are skipped, but
+	ECS stops reporting a failed deployment once a newer deployment has settled, so a revision
+	that failed before the last successful deploy can still be chosen. Use --revision or
+	--commit to pick the revision explicitly in that case.
+	The --revision flag can only be input when the services rolled back share a task definition
+	family.
+	The --dry-run flag can be input to print the changes without rolling back.
+	Values of sensitive keys are masked in the printed changes, the --reveal flag can be input
+	to show them.
+	The --commit flag can be input to roll every service back to the newest revision of its
+	task definition that was deployed with that git commit.`,
 	RunE: runRollback,
 }
 
 func runRollback(cmd *cobra.Command, args []string) error {
-	return rollback(flagCluster, flagTimeout)
+	return rollback(flagCluster, flagService, revisionNumber, flagTimeout)
 }
 
-func rollback(clusterName string, timeout int) error {
-	outback := Outback.New(awsConfig)
+// rollback rolls back one service of a cluster, or every service when serviceName is empty,
+// to the given revision or to the previous deploy when revision is 0
+func rollback(clusterName string, serviceName string, revision int, timeout int) error {
+	outback := Outback.New(cfg.getAwsConfig(clusterName))
 
 	cluster, err := cfg.getCluster(clusterName)
 	if err != nil {
 		return err
 	}
 
-	deployDetail := &Outback.DeployDetail{}
-	deployDetail.SetRevisionNumber(revisionNumber)
+	services := cluster.Services
+	if serviceName != "" {
+		service, err := cfg.getService(cluster.Services, serviceName)
+		if err != nil {
+			return err
+		}
+
+		services = []string{*service}
+	}
 
 	deployment := &Outback.Deployment{}
 
-	for _, service := range cluster.Services {
+	for _, service := range services {
 		detail := outback.NewDeployDetail()
 
 		// Get the ECS Cluster
@@ -65,15 +91,42 @@ func rollback(clusterName string, timeout int) error {
 			return err
 		}
 
-		// Set the TaskDefinition in the deployment detail
+		// Set the TaskDefinition and the revision to roll back to in the deployment detail
 		detail.SetTaskDefinition(ecsTaskDef)
+		detail.SetRevisionNumber(revision)
 
 		deployment.DeployDetails = append(deployment.DeployDetails, detail)
 	}
 
+	if flagRollbackCommit != "" && revision != 0 {
+		return ErrCommitAndRevision
+	}
+
+	if revision != 0 && len(deploymentFamilies(deployment)) > 1 {
+		return ErrRevisionMultipleFamilies
+	}
+
+	if flagDryRun {
+		for _, detail := range deployment.DeployDetails {
+			plan, err := rollbackPlan(outback, detail)
+			if err != nil {
+				return err
+			}
+
+			printPlan(plan)
+		}
+
+		return nil
+	}
+
 	term.Clear()
 
-	errCh := outback.RollbackAll(deployment, deployDetail)
+	var errCh <-chan error
+	if flagRollbackCommit != "" {
+		errCh = outback.RollbackAllToCommit(deployment, flagRollbackCommit)
+	} else {
+		errCh = outback.RollbackAll(deployment)
+	}
 
 	for err := range errCh {
 		return err
@@ -94,7 +147,35 @@ func rollback(clusterName string, timeout int) error {
 	return nil
 }
 
+// rollbackPlan resolves the revision a service would be rolled back to
+func rollbackPlan(outback *Outback.Outback, detail *Outback.DeployDetail) (*Outback.Plan, error) {
+	if flagRollbackCommit != "" {
+		return outback.PlanRollbackToCommit(detail.Cluster, detail.Service, detail.TaskDefinition, flagRollbackCommit)
+	}
+
+	return outback.PlanRollback(detail.Cluster, detail.Service, detail.TaskDefinition, detail.RevisionNumber)
+}
+
+// deploymentFamilies returns the distinct task definition families of the services in a deployment
+func deploymentFamilies(deployment *Outback.Deployment) []string {
+	seen := map[string]bool{}
+	families := make([]string, 0)
+
+	for _, detail := range deployment.DeployDetails {
+		family := aws.StringValue(detail.TaskDefinition.Family)
+		if !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+
+	return families
+}
+
 func init() {
 	rootCmd.AddCommand(rollbackCmd)
 	rollbackCmd.Flags().IntVarP(&revisionNumber, "revision", "r", 0, "Set the task revision number")
+	rollbackCmd.Flags().StringVar(&flagRollbackCommit, "commit", "", "Roll back to the revision deployed with this git commit")
+	rollbackCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without rolling back")
+	rollbackCmd.Flags().BoolVar(&flagReveal, "reveal", false, "Show the values of sensitive keys in the planned changes")
 }
@@ -21,6 +21,9 @@ var (
 	flagService    string
 	flagConfigName string
 	flagTimeout    int
+	flagDryRun     bool
+	flagReveal     bool
+	flagOutput     string
 )
 
 // RootCmd represents the base command when called
@@ -72,8 +75,5 @@ func loadConfig() {
 		}
 	}
 
-	awsConfig = &Outback.AwsConfig{
-		Profile: cfg.Profile,
-		Region:  cfg.Region,
-	}
+	awsConfig = cfg.getAwsConfig(flagCluster)
 }
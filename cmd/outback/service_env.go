@@ -1,14 +1,34 @@
 package cmd
 
 import (
+	"github.com/aws/aws-sdk-go/service/ecs"
+	Outback "github.com/koala-labs/outback/pkg/outback"
 	"github.com/spf13/cobra"
 )
 
+// flagContainer selects the container of the task definition env and secret commands apply to
+var flagContainer string
+
 var serviceEnvCmd = &cobra.Command{
 	Use:   "env",
 	Short: "Manage environment variables",
 }
 
+// listedContainers returns the containers the list commands print, the one selected with
+// --container or every container of the task definition
+func listedContainers(t *ecs.TaskDefinition) ([]*ecs.ContainerDefinition, error) {
+	if flagContainer == "" {
+		return t.ContainerDefinitions, nil
+	}
+
+	i, err := Outback.ContainerIndex(t, flagContainer, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ContainerDefinitions[i : i+1], nil
+}
+
 func init() {
 	serviceCmd.AddCommand(serviceEnvCmd)
 }
@@ -12,17 +12,31 @@ import (
 
 var (
 	flagServiceAddEnvVars []string
+	flagEnvUppercase      bool
 )
 
 var serviceAddEnvCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add/Update environment variables",
 	Long: `At least one environment variable must be specified via the --env flag. Specify
-	--env with a key=value parameter multiple times to add multiple variables.`,
+	--env with a key=value parameter multiple times to add multiple variables.
+	Keys are kept as typed and must only contain letters, digits and underscores. The
+	--uppercase flag can be input to upper-case every key.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is updated.
+	The --dry-run flag can be input to print the changes without applying them.
+	Values of sensitive keys are masked in the printed changes, the --reveal flag can be input
+	to show them.`,
 	RunE: addEnvVar,
 }
 
 func addEnvVar(cmd *cobra.Command, args []string) error {
+	parsedEnvVars, err := stringsToKeyValue(flagServiceAddEnvVars, flagEnvUppercase)
+
+	if err != nil {
+		return err
+	}
+
 	u := Outback.New(awsConfig)
 
 	c, err := u.GetCluster(flagCluster)
@@ -43,20 +57,20 @@ func addEnvVar(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	parsedEnvVars, err := stringsToKeyValue(flagServiceAddEnvVars)
+	updatedDefinition, err := u.UpdateContainerEnvVars(*t, parsedEnvVars, flagContainer, cfg.getRepo(flagCluster))
 
 	if err != nil {
 		return err
 	}
-	updatedDefinition := u.UpdateContainerDefinitionEnvVars(*t, parsedEnvVars, cfg.Repo)
 
-	registeredDefinition, err := u.RegisterTaskDefinitionWithEnvVars(&updatedDefinition)
+	plan := u.NewPlan(c, s, t, &updatedDefinition, true)
 
-	if err != nil {
-		return err
+	if flagDryRun {
+		printPlan(plan)
+		return nil
 	}
 
-	_, err = u.UpdateService(c, s, registeredDefinition)
+	_, err = u.ApplyPlan(plan)
 
 	if err != nil {
 		return err
@@ -67,22 +81,36 @@ func addEnvVar(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func stringsToKeyValue(inputs []string) ([]*ecs.KeyValuePair, error) {
+// stringsToKeyValue parses key=value inputs. Keys are kept as typed unless uppercase is set,
+// must be valid environment variable names and may only be given once.
+func stringsToKeyValue(inputs []string, uppercase bool) ([]*ecs.KeyValuePair, error) {
 	keyVals := make([]*ecs.KeyValuePair, 0)
-
-	if len(inputs) == 0 {
-		return keyVals, nil
-	}
+	seen := map[string]bool{}
 
 	for _, in := range inputs {
 		splitIn := strings.SplitN(in, "=", 2)
 
 		if len(splitIn) != 2 {
-			return nil, ErrInvalidEnvInput
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidEnvInput, in)
+		}
+
+		key := splitIn[0]
+		if uppercase {
+			key = strings.ToUpper(key)
+		}
+
+		// report the key as typed but never the value, which may be a secret
+		if !Outback.ValidEnvName(key) {
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidEnvName, splitIn[0])
+		}
+
+		if seen[key] {
+			return nil, fmt.Errorf("%w: '%s'", ErrDuplicateEnvName, splitIn[0])
 		}
+		seen[key] = true
 
 		keyVal := &ecs.KeyValuePair{
-			Name:  aws.String(strings.ToUpper(splitIn[0])),
+			Name:  aws.String(key),
 			Value: aws.String(splitIn[1]),
 		}
 
@@ -96,4 +124,8 @@ func init() {
 	serviceEnvCmd.AddCommand(serviceAddEnvCmd)
 
 	serviceAddEnvCmd.Flags().StringSliceVarP(&flagServiceAddEnvVars, "env", "e", []string{}, "Environment variables to add e.g. key=value")
+	serviceAddEnvCmd.Flags().BoolVar(&flagEnvUppercase, "uppercase", false, "Upper-case every key")
+	serviceAddEnvCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to update")
+	serviceAddEnvCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without applying them")
+	serviceAddEnvCmd.Flags().BoolVar(&flagReveal, "reveal", false, "Show the values of sensitive keys in the planned changes")
 }
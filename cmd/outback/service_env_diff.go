@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagEnvDiffToCluster    string
+	flagEnvDiffToService    string
+	flagEnvDiffFromRevision int
+	flagEnvDiffToRevision   int
+	flagEnvDiffReveal       bool
+)
+
+var serviceDiffEnvCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare environment variables between clusters or revisions",
+	Long: `A cluster and service must be specified via the --cluster and --service flags.
+	The --to-cluster flag can be input to compare the service with the service of the same
+	name in another cluster, for example staging with prod.
+	The --to-service flag can be input to compare with a service of another name, in the
+	cluster given by --to-cluster or else in the same cluster.
+	The --from-revision and --to-revision flags can be input to compare two revisions of the
+	service's task definition family instead. Either one defaults to the running revision.
+	Added, removed and changed variables and secrets are listed. The deploy markers set by
+	outback deploy are left out and secrets are compared by the ARN they are read from.
+	Values of sensitive keys, those matching the sensitive-keys patterns of the config or by
+	default *_SECRET, *_PASSWORD, *_KEY and *TOKEN*, are masked. The --reveal flag can be
+	input to show them.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is compared.`,
+	RunE: diffEnv,
+}
+
+// envDiffSide is one of the two containers being compared
+type envDiffSide struct {
+	label     string
+	container *ecs.ContainerDefinition
+}
+
+func diffEnv(cmd *cobra.Command, args []string) error {
+	revisions := flagEnvDiffFromRevision != 0 || flagEnvDiffToRevision != 0
+	services := flagEnvDiffToCluster != "" || flagEnvDiffToService != ""
+
+	if services && revisions {
+		return ErrDiffClusterAndRevision
+	}
+
+	if !services && !revisions {
+		return ErrDiffNothingToCompare
+	}
+
+	var from, to *envDiffSide
+	var err error
+
+	if services {
+		toCluster, toService := flagEnvDiffToCluster, flagEnvDiffToService
+
+		if toCluster == "" {
+			toCluster = flagCluster
+		}
+
+		if toService == "" {
+			toService = flagService
+		}
+
+		from, err = clusterEnvDiffSide(flagCluster, flagService)
+		if err != nil {
+			return err
+		}
+
+		to, err = clusterEnvDiffSide(toCluster, toService)
+	} else {
+		from, to, err = revisionEnvDiffSides(flagCluster, flagEnvDiffFromRevision, flagEnvDiffToRevision)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	printEnvDiff(from, to, Outback.EnvDiff(from.container, to.container))
+
+	return nil
+}
+
+// clusterEnvDiffSide selects the container of the running task definition of a service in
+// a cluster
+func clusterEnvDiffSide(clusterName string, serviceName string) (*envDiffSide, error) {
+	_, t, err := serviceTaskDefinition(clusterName, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEnvDiffSide(clusterName, serviceName, t, cfg.getRepo(clusterName))
+}
+
+// revisionEnvDiffSides selects the containers of two revisions of the task definition family
+// of the service in a cluster, a revision of 0 being the running revision
+func revisionEnvDiffSides(clusterName string, fromRevision int, toRevision int) (*envDiffSide, *envDiffSide, error) {
+	outback, t, err := serviceTaskDefinition(clusterName, flagService)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	family, _ := Outback.ParseTaskDefinitionArn(*t.TaskDefinitionArn)
+	repo := cfg.getRepo(clusterName)
+	sides := make([]*envDiffSide, 2)
+
+	for i, revision := range []int{fromRevision, toRevision} {
+		revisionTaskDef := t
+
+		if revision != 0 {
+			revisionTaskDef, err = outback.GetTaskDefinitionRevision(family, revision)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		sides[i], err = newEnvDiffSide(clusterName, flagService, revisionTaskDef, repo)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return sides[0], sides[1], nil
+}
+
+func newEnvDiffSide(clusterName string, serviceName string, t *ecs.TaskDefinition, repo string) (*envDiffSide, error) {
+	i, err := Outback.ContainerIndex(t, flagContainer, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	family, revision := Outback.ParseTaskDefinitionArn(*t.TaskDefinitionArn)
+
+	return &envDiffSide{
+		label:     fmt.Sprintf("%s/%s (%s:%d)", clusterName, serviceName, family, revision),
+		container: t.ContainerDefinitions[i],
+	}, nil
+}
+
+// serviceTaskDefinition returns the running task definition of a service in a cluster using
+// the AWS profile and region of that cluster
+func serviceTaskDefinition(clusterName string, serviceName string) (*Outback.Outback, *ecs.TaskDefinition, error) {
+	cluster, err := cfg.getCluster(clusterName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	service, err := cfg.getService(cluster.Services, serviceName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outback := Outback.New(cfg.getAwsConfig(clusterName))
+
+	c, err := outback.GetCluster(cluster.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := outback.GetService(c, *service)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t, err := outback.GetTaskDefinition(c, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return outback, t, nil
+}
+
+// printEnvDiff prints the changes from one container environment to another in the same
+// layout as printPlan
+func printEnvDiff(from *envDiffSide, to *envDiffSide, changes []Outback.PlanChange) {
+	fmt.Printf("Environment: %s -> %s\n", from.label, to.label)
+
+	if len(changes) == 0 {
+		fmt.Printf("  no differences\n")
+		return
+	}
+
+	patterns := cfg.getSensitiveKeys()
+	if flagEnvDiffReveal {
+		patterns = nil
+	}
+
+	for _, c := range changes {
+		c = c.Masked(patterns)
+
+		switch c.Action {
+		case Outback.PlanActionAdd:
+			fmt.Printf("  + %s: %s\n", c.Field, c.After)
+		case Outback.PlanActionRemove:
+			fmt.Printf("  - %s: %s\n", c.Field, c.Before)
+		default:
+			fmt.Printf("  ~ %s: %s -> %s\n", c.Field, c.Before, c.After)
+		}
+	}
+}
+
+func init() {
+	serviceEnvCmd.AddCommand(serviceDiffEnvCmd)
+
+	serviceDiffEnvCmd.Flags().StringVar(&flagEnvDiffToCluster, "to-cluster", "", "Cluster to compare the service with")
+	serviceDiffEnvCmd.Flags().IntVar(&flagEnvDiffFromRevision, "from-revision", 0, "Revision to compare from, defaults to the running revision")
+	serviceDiffEnvCmd.Flags().IntVar(&flagEnvDiffToRevision, "to-revision", 0, "Revision to compare to, defaults to the running revision")
+	serviceDiffEnvCmd.Flags().StringVar(&flagEnvDiffToService, "to-service", "", "Service to compare with, defaults to --service")
+	serviceDiffEnvCmd.Flags().BoolVar(&flagEnvDiffReveal, "reveal", false, "Show the values of sensitive keys")
+	serviceDiffEnvCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to compare")
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var flagEnvExportFormat string
+
+var serviceExportEnvCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export environment variables as a dotenv or json file",
+	Long: `Prints the environment of the service's container sorted by name, so it can be
+	redirected to a file, reviewed and applied again with env import.
+	The deploy markers set by outback deploy and secrets are left out.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is exported.
+	The --format flag can be input to print the environment as json instead of dotenv.`,
+	RunE: exportEnv,
+}
+
+func exportEnv(cmd *cobra.Command, args []string) error {
+	cfgCluster, err := cfg.getCluster(flagCluster)
+	if err != nil {
+		return err
+	}
+
+	cfgService, err := cfg.getService(cfgCluster.Services, flagService)
+	if err != nil {
+		return err
+	}
+
+	outback := Outback.New(awsConfig)
+
+	c, err := outback.GetCluster(cfgCluster.Name)
+	if err != nil {
+		return err
+	}
+
+	s, err := outback.GetService(c, *cfgService)
+	if err != nil {
+		return err
+	}
+
+	t, err := outback.GetTaskDefinition(c, s)
+	if err != nil {
+		return err
+	}
+
+	env, err := Outback.ContainerEnv(t, flagContainer, cfg.getRepo(flagCluster))
+	if err != nil {
+		return err
+	}
+
+	return Outback.FormatEnv(os.Stdout, env, flagEnvExportFormat)
+}
+
+func init() {
+	serviceEnvCmd.AddCommand(serviceExportEnvCmd)
+
+	serviceExportEnvCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to export")
+	serviceExportEnvCmd.Flags().StringVar(&flagEnvExportFormat, "format", Outback.EnvFormatDotenv, "Output format (dotenv or json)")
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var serviceEnvFileCmd = &cobra.Command{
+	Use:   "file",
+	Short: "Manage environment files stored in S3",
+}
+
+var serviceListEnvFileCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the environment files of a service",
+	Long: `Lists the environment files of every container of the service.
+	The --container flag can be input to list a single container by name.`,
+	RunE: listEnvFiles,
+}
+
+func listEnvFiles(cmd *cobra.Command, args []string) error {
+	cfgCluster, err := cfg.getCluster(flagCluster)
+	if err != nil {
+		return err
+	}
+
+	cfgService, err := cfg.getService(cfgCluster.Services, flagService)
+	if err != nil {
+		return err
+	}
+
+	outback := Outback.New(awsConfig)
+
+	c, err := outback.GetCluster(cfgCluster.Name)
+	if err != nil {
+		return err
+	}
+
+	s, err := outback.GetService(c, *cfgService)
+	if err != nil {
+		return err
+	}
+
+	t, err := outback.GetTaskDefinition(c, s)
+	if err != nil {
+		return err
+	}
+
+	containers, err := listedContainers(t)
+	if err != nil {
+		return err
+	}
+
+	printEnvFileTable(containers)
+
+	return nil
+}
+
+func printEnvFileTable(containers []*ecs.ContainerDefinition) {
+	rows := make([][]string, 0)
+
+	for _, container := range containers {
+		for _, file := range container.EnvironmentFiles {
+			rows = append(rows, []string{aws.StringValue(container.Name), aws.StringValue(file.Value)})
+		}
+	}
+
+	printTable([]string{"Container", "Environment File"}, rows)
+}
+
+func init() {
+	serviceEnvCmd.AddCommand(serviceEnvFileCmd)
+	serviceEnvFileCmd.AddCommand(serviceListEnvFileCmd)
+
+	serviceListEnvFileCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to list")
+}
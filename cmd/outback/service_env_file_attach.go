@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var serviceAttachEnvFileCmd = &cobra.Command{
+	Use:   "attach <s3-arn>",
+	Short: "Attach an environment file stored in S3",
+	Long: `Adds the S3 object, given as arn:aws:s3:::bucket/key, to the environment files of
+	the service's container and registers a new revision.
+	The task execution role of the service must be allowed to read the object.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is updated.
+	The --dry-run flag can be input to print the changes without applying them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: attachEnvFile,
+}
+
+func attachEnvFile(cmd *cobra.Command, args []string) error {
+	return applyEnvFile(args[0])
+}
+
+// applyEnvFile attaches an environment file to the service and registers a new revision
+func applyEnvFile(arn string) error {
+	u := Outback.New(awsConfig)
+
+	c, err := u.GetCluster(flagCluster)
+
+	if err != nil {
+		return err
+	}
+
+	s, err := u.GetService(c, flagService)
+
+	if err != nil {
+		return err
+	}
+
+	t, err := u.GetTaskDefinition(c, s)
+
+	if err != nil {
+		return err
+	}
+
+	newDefinition, err := u.AttachContainerEnvFile(*t, arn, flagContainer, cfg.getRepo(flagCluster))
+
+	if err != nil {
+		return err
+	}
+
+	plan := u.NewPlan(c, s, t, &newDefinition, true)
+
+	if flagDryRun {
+		printPlan(plan)
+		return nil
+	}
+
+	if !plan.HasChanges() {
+		fmt.Printf("Environment file %s is already attached\n", arn)
+		return nil
+	}
+
+	_, err = u.ApplyPlan(plan)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Environment file %s will be attached\n", arn)
+
+	return nil
+}
+
+func init() {
+	serviceEnvFileCmd.AddCommand(serviceAttachEnvFileCmd)
+
+	serviceAttachEnvFileCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to update")
+	serviceAttachEnvFileCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without applying them")
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var serviceDetachEnvFileCmd = &cobra.Command{
+	Use:   "detach <s3-arn>",
+	Short: "Detach an environment file",
+	Long: `Removes the S3 object from the environment files of the service's container and
+	registers a new revision. The object itself is kept so earlier revisions can still be
+	rolled back to.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is updated.
+	The --dry-run flag can be input to print the changes without applying them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: detachEnvFile,
+}
+
+func detachEnvFile(cmd *cobra.Command, args []string) error {
+	u := Outback.New(awsConfig)
+
+	c, err := u.GetCluster(flagCluster)
+
+	if err != nil {
+		return err
+	}
+
+	s, err := u.GetService(c, flagService)
+
+	if err != nil {
+		return err
+	}
+
+	t, err := u.GetTaskDefinition(c, s)
+
+	if err != nil {
+		return err
+	}
+
+	newDefinition, detached, err := u.DetachContainerEnvFile(*t, args[0], flagContainer, cfg.getRepo(flagCluster))
+
+	if err != nil {
+		return err
+	}
+
+	if !detached {
+		return ErrEnvFileNotAttached
+	}
+
+	plan := u.NewPlan(c, s, t, &newDefinition, true)
+
+	if flagDryRun {
+		printPlan(plan)
+		return nil
+	}
+
+	_, err = u.ApplyPlan(plan)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Environment file %s will be detached\n", args[0])
+
+	return nil
+}
+
+func init() {
+	serviceEnvFileCmd.AddCommand(serviceDetachEnvFileCmd)
+
+	serviceDetachEnvFileCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to update")
+	serviceDetachEnvFileCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without applying them")
+}
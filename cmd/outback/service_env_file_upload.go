@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var serviceUploadEnvFileCmd = &cobra.Command{
+	Use:   "upload <file>",
+	Short: "Upload an environment file to S3 and attach it",
+	Long: `Validates a local environment file with the rules ECS reads it by (KEY=value lines and
+	# comments, without quote or export processing), uploads it to the env-file-bucket of the
+	config under outback/<cluster>/<service>/ and attaches it to the service's container in a
+	new revision. The object key contains a hash of the file, so every upload creates a new
+	object and earlier revisions keep reading the file they were registered with. The new
+	revision reads the new object in place of an earlier upload of the same file name.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is updated.
+	The --dry-run flag can be input to print the changes without uploading or applying them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: uploadEnvFile,
+}
+
+func uploadEnvFile(cmd *cobra.Command, args []string) error {
+	bucket := cfg.getEnvFileBucket(flagCluster)
+	if bucket == "" {
+		return ErrNoEnvFileBucket
+	}
+
+	contents, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	// validate before a dry run as well, so it fails where the upload would
+	if _, err := Outback.ParseEnvFile(bytes.NewReader(contents)); err != nil {
+		return err
+	}
+
+	key := Outback.EnvFileKey(flagCluster, flagService, args[0], contents)
+	arn := Outback.S3ObjectArn(bucket, key)
+
+	if !flagDryRun {
+		arn, err = Outback.New(awsConfig).UploadEnvFile(bucket, key, contents)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Uploaded %s to %s\n", args[0], arn)
+	}
+
+	return applyEnvFile(arn)
+}
+
+func init() {
+	serviceEnvFileCmd.AddCommand(serviceUploadEnvFileCmd)
+
+	serviceUploadEnvFileCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to update")
+	serviceUploadEnvFileCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without uploading or applying them")
+}
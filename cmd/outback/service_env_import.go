@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagEnvImportFormat  string
+	flagEnvImportReplace bool
+	flagEnvImportYes     bool
+)
+
+var serviceImportEnvCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import environment variables from a dotenv or json file",
+	Long: `Adds or updates every variable in the file in a single task definition revision.
+	Files ending in .json are read as a json object, any other file as dotenv.
+	The --format flag can be input to choose the format explicitly.
+	The --replace flag can be input to also remove the variables that are not in the file.
+	The deploy markers set by outback deploy are always kept.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is updated.
+	The changes are printed and must be confirmed before they are applied. The --yes flag
+	can be input to skip the confirmation, the --dry-run flag to only print the changes.
+	Values of sensitive keys are masked in the printed changes, the --reveal flag can be input
+	to show them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: importEnv,
+}
+
+func importEnv(cmd *cobra.Command, args []string) error {
+	format := flagEnvImportFormat
+	if format == "" {
+		format = Outback.EnvFormatDotenv
+		if filepath.Ext(args[0]) == ".json" {
+			format = Outback.EnvFormatJSON
+		}
+	}
+
+	file, err := os.Open(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	parsed, err := Outback.ParseEnv(file, format)
+
+	if err != nil {
+		return err
+	}
+
+	// the deploy markers belong to the deploy that registered the running revision
+	env := make([]*ecs.KeyValuePair, 0, len(parsed))
+	for _, kv := range parsed {
+		if !Outback.IsDeployEnv(aws.StringValue(kv.Name)) {
+			env = append(env, kv)
+		}
+	}
+
+	u := Outback.New(awsConfig)
+
+	c, err := u.GetCluster(flagCluster)
+
+	if err != nil {
+		return err
+	}
+
+	s, err := u.GetService(c, flagService)
+
+	if err != nil {
+		return err
+	}
+
+	t, err := u.GetTaskDefinition(c, s)
+
+	if err != nil {
+		return err
+	}
+
+	repo := cfg.getRepo(flagCluster)
+	newDefinition, err := u.UpdateContainerEnvVars(*t, env, flagContainer, repo)
+
+	if err != nil {
+		return err
+	}
+
+	if flagEnvImportReplace {
+		current, err := Outback.ContainerEnv(&newDefinition, flagContainer, repo)
+
+		if err != nil {
+			return err
+		}
+
+		newDefinition, _, err = u.RemoveContainerEnvVars(newDefinition, missingEnvVars(current, env), flagContainer, repo)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	plan := u.NewPlan(c, s, t, &newDefinition, true)
+	printPlan(plan)
+
+	if flagDryRun || !plan.HasChanges() {
+		return nil
+	}
+
+	if !flagEnvImportYes {
+		confirmed, err := askConfirm("Are you sure you want to apply these changes?")
+
+		if err != nil || !confirmed {
+			return err
+		}
+	}
+
+	_, err = u.ApplyPlan(plan)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Environment variable(s) from %s will be applied\n", args[0])
+
+	return nil
+}
+
+// missingEnvVars returns the names in current that are not set in env, except the deploy markers
+func missingEnvVars(current []*ecs.KeyValuePair, env []*ecs.KeyValuePair) []string {
+	keep := map[string]bool{}
+	for _, kv := range env {
+		keep[aws.StringValue(kv.Name)] = true
+	}
+
+	missing := make([]string, 0)
+	for _, kv := range current {
+		name := aws.StringValue(kv.Name)
+		if !keep[name] && !Outback.IsDeployEnv(name) {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+func init() {
+	serviceEnvCmd.AddCommand(serviceImportEnvCmd)
+
+	serviceImportEnvCmd.Flags().StringVar(&flagEnvImportFormat, "format", "", "Input format (dotenv or json), defaults to the file extension")
+	serviceImportEnvCmd.Flags().BoolVar(&flagEnvImportReplace, "replace", false, "Remove variables that are not in the file")
+	serviceImportEnvCmd.Flags().BoolVarP(&flagEnvImportYes, "yes", "y", false, "Apply the changes without asking for confirmation")
+	serviceImportEnvCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to update")
+	serviceImportEnvCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without applying them")
+	serviceImportEnvCmd.Flags().BoolVar(&flagReveal, "reveal", false, "Show the values of sensitive keys in the planned changes")
+}
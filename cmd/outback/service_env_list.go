@@ -2,20 +2,38 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	Outback "github.com/koala-labs/outback/pkg/outback"
 	"github.com/spf13/cobra"
 )
 
+// secretPlaceholder is listed in place of the value of a secret
+const secretPlaceholder = "(secret)"
+
+var (
+	flagEnvListReveal bool
+	flagEnvListOutput string
+)
+
 var serviceListEnvCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List environment variables",
-	Run:   listEnv,
+	Long: `Lists the environment variables of every container of the service.
+	Values of keys matching the sensitive-keys patterns of the config, by default *_SECRET,
+	*_PASSWORD, *_KEY and *TOKEN*, are masked. The --reveal flag can be input to show them.
+	The --container flag can be input to list a single container by name.
+	The --output flag can be input to print the environment as json, keyed by container
+	name, or as dotenv for a single container. Secrets are only listed in the table.`,
+	Run: listEnv,
 }
 
 func listEnv(cmd *cobra.Command, args []string) {
+	handleError(validateOutput(flagEnvListOutput, outputTable, outputJSON, Outback.EnvFormatDotenv))
+
 	cfgCluster, err := cfg.getCluster(flagCluster)
 
 	handleError(err)
@@ -38,18 +56,63 @@ func listEnv(cmd *cobra.Command, args []string) {
 
 	handleError(err)
 
-	printEnvTable(t)
+	patterns := cfg.getSensitiveKeys()
+	if flagEnvListReveal {
+		patterns = nil
+	}
+
+	switch flagEnvListOutput {
+	case Outback.EnvFormatDotenv:
+		env, err := Outback.ContainerEnv(t, flagContainer, cfg.getRepo(flagCluster))
+
+		handleError(err)
+		handleError(Outback.FormatEnv(os.Stdout, Outback.MaskEnv(env, patterns), Outback.EnvFormatDotenv))
+	case outputJSON:
+		containers, err := listedContainers(t)
+
+		handleError(err)
+		handleError(printJSON(envByContainer(containers, patterns)))
+	default:
+		containers, err := listedContainers(t)
+
+		handleError(err)
+
+		printEnvTable(containers, patterns)
+	}
 }
 
-func printEnvTable(t *ecs.TaskDefinition) {
-	for _, containerDefinition := range t.ContainerDefinitions {
-		longestName, longestValue := longestNameAndValue(containerDefinition.Environment)
+// envByContainer maps container names to their environment, masking sensitive values
+func envByContainer(containers []*ecs.ContainerDefinition, patterns []string) map[string]map[string]string {
+	envs := make(map[string]map[string]string, len(containers))
+
+	for _, container := range containers {
+		env := map[string]string{}
+		for _, kv := range Outback.MaskEnv(container.Environment, patterns) {
+			env[aws.StringValue(kv.Name)] = aws.StringValue(kv.Value)
+		}
+
+		envs[aws.StringValue(container.Name)] = env
+	}
+
+	return envs
+}
+
+// printEnvTable prints the environment of every container, masking the values of keys that
+// match patterns. Secrets are listed by name only, their values are never read.
+func printEnvTable(containers []*ecs.ContainerDefinition, patterns []string) {
+	for _, containerDefinition := range containers {
+		environment := Outback.MaskEnv(containerDefinition.Environment, patterns)
+		for _, secret := range containerDefinition.Secrets {
+			environment = append(environment, &ecs.KeyValuePair{Name: secret.Name, Value: aws.String(secretPlaceholder)})
+		}
+
+		longestName, longestValue := longestNameAndValue(environment)
 		nameDashes := strings.Repeat("-", longestName+2) // Adding two because of the table padding
 		valueDashes := strings.Repeat("-", longestValue+2)
 
 		fmt.Printf("+%s+%s+\n", nameDashes, valueDashes)
 
-		for _, value := range containerDefinition.Environment {
+		for _, value := range environment {
 			name := *value.Name
 			value := *value.Value
 			nameSpaces := longestName - len(name)
@@ -79,4 +142,8 @@ func longestNameAndValue(e []*ecs.KeyValuePair) (longName int, longVal int) {
 
 func init() {
 	serviceEnvCmd.AddCommand(serviceListEnvCmd)
+
+	serviceListEnvCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to list")
+	serviceListEnvCmd.Flags().BoolVar(&flagEnvListReveal, "reveal", false, "Show the values of sensitive keys")
+	serviceListEnvCmd.Flags().StringVarP(&flagEnvListOutput, "output", "o", outputTable, "Output format (table, json or dotenv)")
 }
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/service/ecs"
 	Outback "github.com/koala-labs/outback/pkg/outback"
 	"github.com/spf13/cobra"
 )
@@ -17,7 +16,12 @@ var serviceRmEnvCmd = &cobra.Command{
 	Use:   "rm",
 	Short: "Remove environment variables",
 	Long: `Removes the environment variable specified via the --key flag. Specify --key with
-	a key name multiple times to unset multiple variables.`,
+	a key name multiple times to unset multiple variables.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is updated.
+	The --dry-run flag can be input to print the changes without applying them.
+	Values of sensitive keys are masked in the printed changes, the --reveal flag can be input
+	to show them.`,
 	RunE: rmEnv,
 }
 
@@ -42,19 +46,24 @@ func rmEnv(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	newDefinition, err := removeEnvVarsFromTaskDefinition(t, flagServiceRmEnvVars)
+	newDefinition, removed, err := u.RemoveContainerEnvVars(*t, flagServiceRmEnvVars, flagContainer, cfg.getRepo(flagCluster))
 
 	if err != nil {
 		return err
 	}
 
-	registeredDefinition, err := u.RegisterTaskDefinitionWithEnvVars(newDefinition)
+	if len(removed) == 0 {
+		return ErrKeyNotPresent
+	}
 
-	if err != nil {
-		return err
+	plan := u.NewPlan(c, s, t, &newDefinition, true)
+
+	if flagDryRun {
+		printPlan(plan)
+		return nil
 	}
 
-	_, err = u.UpdateService(c, s, registeredDefinition)
+	_, err = u.ApplyPlan(plan)
 
 	if err != nil {
 		return err
@@ -65,34 +74,11 @@ func rmEnv(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func removeEnvVarsFromTaskDefinition(t *ecs.TaskDefinition, removals []string) (*ecs.TaskDefinition, error) {
-	encountered := map[string]bool{}
-
-	for _, r := range removals {
-		encountered[r] = true
-	}
-
-	current := t.ContainerDefinitions[0].Environment
-
-	newSet := []*ecs.KeyValuePair{}
-
-	for _, c := range current {
-		if _, ok := encountered[*c.Name]; !ok {
-			newSet = append(newSet, c)
-		}
-	}
-
-	if len(current) == len(newSet) {
-		return nil, ErrKeyNotPresent
-	}
-
-	t.ContainerDefinitions[0].Environment = newSet
-
-	return t, nil
-}
-
 func init() {
 	serviceEnvCmd.AddCommand(serviceRmEnvCmd)
 
 	serviceRmEnvCmd.Flags().StringSliceVarP(&flagServiceRmEnvVars, "key", "k", []string{}, "Environment variables to remove e.g. APP_ENV")
+	serviceRmEnvCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to update")
+	serviceRmEnvCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without applying them")
+	serviceRmEnvCmd.Flags().BoolVar(&flagReveal, "reveal", false, "Show the values of sensitive keys in the planned changes")
 }
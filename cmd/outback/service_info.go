@@ -2,20 +2,33 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/service/ecs"
 	Outback "github.com/koala-labs/outback/pkg/outback"
 	"github.com/spf13/cobra"
 )
 
+var (
+	flagInfoEvents int
+	flagInfoOutput string
+)
+
 var serviceInfoCmd = &cobra.Command{
 	Use:   "info",
 	Short: "List information of currently deploy service",
-	Run:   listInfo,
+	Long: `A cluster and service must be specified via the --cluster and --service flags.
+	Lists the active task definition, the deployments of the service with their rollout state
+	and task counts, the load balancer target groups, the running and recently stopped tasks
+	with their health and stopped reason, and the newest service events.
+	The --events flag can be input to change the number of events listed.
+	The --output flag can be input to print the information as json.`,
+	Run: listInfo,
 }
 
 func listInfo(cmd *cobra.Command, args []string) {
+	handleError(validateOutput(flagInfoOutput, outputTable, outputJSON))
+
 	cfgCluster, err := cfg.getCluster(flagCluster)
 
 	handleError(err)
@@ -34,26 +47,33 @@ func listInfo(cmd *cobra.Command, args []string) {
 
 	handleError(err)
 
-	t, err := outback.GetTaskDefinition(c, s)
+	detail, err := outback.GetServiceDetail(c, s, flagInfoEvents)
 
 	handleError(err)
 
-	printServiceInfoTable(t)
-
-	fmt.Printf("\n")
+	if flagInfoOutput == outputJSON {
+		handleError(printJSON(detail))
+		return
+	}
 
-	runningTasks, err := outback.RunningTasks(c, s)
+	printServiceInfoTable(detail)
 
-	handleError(err)
+	fmt.Printf("\nDeployments\n")
+	printDeploymentTable(detail.Deployments)
 
-	tasks, err := outback.GetTasks(c, runningTasks)
+	if len(detail.LoadBalancers) > 0 {
+		fmt.Printf("\nLoad Balancers\n")
+		printLoadBalancerTable(detail.LoadBalancers)
+	}
 
-	handleError(err)
+	fmt.Printf("\nTasks\n")
+	printTaskTable(detail.Tasks)
 
-	printRunningTaskTable(tasks)
+	fmt.Printf("\nEvents\n")
+	printEventTable(detail.Events)
 }
 
-func printServiceInfoTable(t *ecs.TaskDefinition) {
+func printServiceInfoTable(detail *Outback.ServiceDetail) {
 	longestName := 20
 	longestValue := 100
 	nameDashes := strings.Repeat("-", longestName+2) // Adding two because of the table padding
@@ -61,57 +81,103 @@ func printServiceInfoTable(t *ecs.TaskDefinition) {
 	fmt.Printf("Active Task\n")
 	fmt.Printf("+%s+%s+\n", nameDashes, valueDashes)
 
-	for _, containerDefinition := range t.ContainerDefinitions {
-		imageName := "Image"
-		imageValue := *containerDefinition.Image
-		imageNameSpaces := longestName - len(imageName)
-		imageValueSpaces := longestValue - len(imageValue)
-		imageSpacesForName := strings.Repeat(" ", imageNameSpaces)
-		imageSpacesForValue := strings.Repeat(" ", imageValueSpaces)
-		fmt.Printf("| %s%s | %s%s |\n", imageName, imageSpacesForName, imageValue, imageSpacesForValue)
+	printRow := func(name string, value string) {
+		spacesForName := strings.Repeat(" ", tablePadding(longestName, name))
+		spacesForValue := strings.Repeat(" ", tablePadding(longestValue, value))
+		fmt.Printf("| %s%s | %s%s |\n", name, spacesForName, value, spacesForValue)
 		fmt.Printf("+%s+%s+\n", nameDashes, valueDashes)
 	}
 
-	revisionName := "Revision"
-	revisionValue := string(*t.TaskDefinitionArn)
-	revisionNameSpaces := longestName - len(revisionName)
-	revisionValueSpaces := longestValue - len(revisionValue)
-	revisionSpacesForName := strings.Repeat(" ", revisionNameSpaces)
-	revisionSpacesForValue := strings.Repeat(" ", revisionValueSpaces)
-	fmt.Printf("| %s%s | %s%s |\n", revisionName, revisionSpacesForName, revisionValue, revisionSpacesForValue)
-	fmt.Printf("+%s+%s+\n", nameDashes, valueDashes)
+	for _, container := range detail.Containers {
+		printRow(container.Name, container.Image)
+	}
 
-	statusName := "Status"
-	statusValue := *t.Status
-	statusNameSpaces := longestName - len(statusName)
-	statusValueSpaces := longestValue - len(statusValue)
-	statusSpacesForName := strings.Repeat(" ", statusNameSpaces)
-	statusSpacesForValue := strings.Repeat(" ", statusValueSpaces)
-	fmt.Printf("| %s%s | %s%s |\n", statusName, statusSpacesForName, statusValue, statusSpacesForValue)
-	fmt.Printf("+%s+%s+\n", nameDashes, valueDashes)
+	printRow("Revision", detail.TaskDefinition.TaskDefinitionArn)
+	printRow("Status", detail.TaskDefinition.Status)
 }
 
-func printRunningTaskTable(tasks []*ecs.Task) {
+// tablePadding returns the number of spaces that fill a column of width after value. Values
+// longer than the column overflow it instead of being padded.
+func tablePadding(width int, value string) int {
+	if len(value) > width {
+		return 0
+	}
 
-	longestName := 20
-	longestValue := 100
-	nameDashes := strings.Repeat("-", longestName+2) // Adding two because of the table padding
-	valueDashes := strings.Repeat("-", longestValue+2)
-	fmt.Printf("Running Tasks\n")
-	fmt.Printf("+%s+%s+\n", nameDashes, valueDashes)
+	return width - len(value)
+}
 
-	for _, task := range tasks {
-		imageName := "Task Definition"
-		imageValue := *task.TaskDefinitionArn
-		imageNameSpaces := longestName - len(imageName)
-		imageValueSpaces := longestValue - len(imageValue)
-		imageSpacesForName := strings.Repeat(" ", imageNameSpaces)
-		imageSpacesForValue := strings.Repeat(" ", imageValueSpaces)
-		fmt.Printf("| %s%s | %s%s |\n", imageName, imageSpacesForName, imageValue, imageSpacesForValue)
-		fmt.Printf("+%s+%s+\n", nameDashes, valueDashes)
+func printDeploymentTable(deployments []*Outback.DeploymentDetail) {
+	rows := make([][]string, len(deployments))
+
+	for i, d := range deployments {
+		family, revision := Outback.ParseTaskDefinitionArn(d.TaskDefinitionArn)
+
+		rows[i] = []string{
+			d.Status,
+			d.RolloutState,
+			fmt.Sprintf("%s:%d", family, revision),
+			strconv.FormatInt(d.RunningCount, 10),
+			strconv.FormatInt(d.PendingCount, 10),
+			strconv.FormatInt(d.DesiredCount, 10),
+			d.UpdatedAt,
+		}
+	}
+
+	printTable([]string{"Status", "Rollout State", "Revision", "Running", "Pending", "Desired", "Updated At"}, rows)
+}
+
+func printLoadBalancerTable(loadBalancers []*Outback.LoadBalancerDetail) {
+	rows := make([][]string, len(loadBalancers))
+
+	for i, lb := range loadBalancers {
+		target := lb.TargetGroupArn
+		if target == "" {
+			target = lb.LoadBalancerName
+		}
+
+		rows[i] = []string{target, lb.ContainerName, strconv.FormatInt(lb.ContainerPort, 10)}
+	}
+
+	printTable([]string{"Target Group", "Container", "Port"}, rows)
+}
+
+func printTaskTable(tasks []*Outback.TaskDetail) {
+	rows := make([][]string, len(tasks))
+
+	for i, task := range tasks {
+		family, revision := Outback.ParseTaskDefinitionArn(task.TaskDefinitionArn)
+
+		rows[i] = []string{
+			taskID(task.TaskArn),
+			fmt.Sprintf("%s:%d", family, revision),
+			task.LastStatus,
+			task.HealthStatus,
+			task.StartedAt,
+			task.StoppedReason,
+		}
+	}
+
+	printTable([]string{"Task", "Revision", "Last Status", "Health", "Started At", "Stopped Reason"}, rows)
+}
+
+func printEventTable(events []*Outback.EventDetail) {
+	rows := make([][]string, len(events))
+
+	for i, event := range events {
+		rows[i] = []string{event.CreatedAt, event.Message}
 	}
+
+	printTable([]string{"Time", "Message"}, rows)
+}
+
+// taskID returns the ID at the end of a task ARN
+func taskID(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
 }
 
 func init() {
 	serviceCmd.AddCommand(serviceInfoCmd)
+
+	serviceInfoCmd.Flags().IntVar(&flagInfoEvents, "events", 10, "Number of service events to list")
+	serviceInfoCmd.Flags().StringVarP(&flagInfoOutput, "output", "o", outputTable, "Output format (table or json)")
 }
@@ -147,7 +147,13 @@ func (o *LogsOperation) parseTime(rawTime string) (time.Time, error) {
 }
 
 func followLogs(o *LogsOperation) {
+	followLogsUntil(o, nil)
+}
+
+// followLogsUntil follows logs until stop is closed, then fetches the logs one last time
+func followLogsUntil(o *LogsOperation, stop <-chan struct{}) {
 	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
 	if o.StartTime.IsZero() {
 		o.StartTime = time.Now()
@@ -160,7 +166,12 @@ func followLogs(o *LogsOperation) {
 			o.StartTime = newStartTime
 		}
 
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-stop:
+			getLogs(o)
+			return
+		}
 	}
 }
 
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var serviceRestartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Replace the tasks of services without changing their task definition",
+	Long: `A cluster must be specified via the --cluster flag.
+	The --service flag can be input to restart a single service, by default every service of
+	the cluster in the config is restarted.
+	A new deployment is forced with the current task definition, so the new tasks read the
+	current values of their secrets and environment files, for example after rotating a secret
+	in SSM. The command waits until the new tasks are running and the old tasks have drained,
+	or until the --timeout in minutes is reached.`,
+	RunE: runRestart,
+}
+
+func runRestart(cmd *cobra.Command, args []string) error {
+	return restart(flagCluster, flagService, flagTimeout)
+}
+
+// restart forces a new deployment of one service of a cluster, or every service when
+// serviceName is empty, and waits for it to complete
+func restart(clusterName string, serviceName string, timeout int) error {
+	outback := Outback.New(cfg.getAwsConfig(clusterName))
+
+	cluster, err := cfg.getCluster(clusterName)
+	if err != nil {
+		return err
+	}
+
+	services := cluster.Services
+	if serviceName != "" {
+		service, err := cfg.getService(cluster.Services, serviceName)
+		if err != nil {
+			return err
+		}
+
+		services = []string{*service}
+	}
+
+	ecsCluster, err := outback.GetCluster(cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	deployment := &Outback.Deployment{}
+
+	for _, service := range services {
+		detail := outback.NewDeployDetail()
+		detail.SetCluster(ecsCluster)
+
+		ecsService, err := outback.GetService(ecsCluster, service)
+		if err != nil {
+			return err
+		}
+
+		detail.SetService(ecsService)
+
+		deployment.DeployDetails = append(deployment.DeployDetails, detail)
+	}
+
+	for err := range outback.RestartAll(deployment) {
+		return err
+	}
+
+	fmt.Printf("Waiting for the new tasks of services [ %s] to replace the old tasks\n", deployment.Services())
+	doneCh := outback.AwaitServicesRestarted(deployment)
+	timeoutCh := time.After(time.Minute * time.Duration(timeout))
+
+	for i := 0; i < len(deployment.DeployDetails); i++ {
+		select {
+		case detail := <-doneCh:
+			fmt.Printf("Service %s has been restarted\n", *detail.Service.ServiceName)
+		case <-timeoutCh:
+			return ErrRestartTimeout
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	serviceCmd.AddCommand(serviceRestartCmd)
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagScaleCount int64
+	flagScaleMin   int64
+	flagScaleMax   int64
+)
+
+var serviceScaleCmd = &cobra.Command{
+	Use:   "scale",
+	Short: "Change the number of tasks of a service",
+	Long: `A cluster and service must be specified via the --cluster and --service flags.
+	The --count flag sets the desired count of the service and waits until that many tasks
+	are running, or until the --timeout in minutes is reached.
+	The --min and --max flags can be input to set the capacity range of the service's
+	Application Auto Scaling target. Both are required when the service is not a scalable
+	target yet. A --count outside of the capacity range is rejected, as auto scaling would
+	move the service back into range.`,
+	RunE: scaleService,
+}
+
+func scaleService(cmd *cobra.Command, args []string) error {
+	countSet := cmd.Flags().Changed("count")
+	minSet := cmd.Flags().Changed("min")
+	maxSet := cmd.Flags().Changed("max")
+
+	if !countSet && !minSet && !maxSet {
+		return ErrScaleNothingToChange
+	}
+
+	if countSet && flagScaleCount < 0 {
+		return fmt.Errorf("%w: '%d'", ErrInvalidScaleCount, flagScaleCount)
+	}
+
+	u := Outback.New(awsConfig)
+
+	c, err := u.GetCluster(flagCluster)
+
+	if err != nil {
+		return err
+	}
+
+	s, err := u.GetService(c, flagService)
+
+	if err != nil {
+		return err
+	}
+
+	target, err := u.GetScalableTarget(c, s)
+
+	if err != nil {
+		return err
+	}
+
+	if minSet || maxSet {
+		target, err = updateScalableTarget(u, c, s, target, minSet, maxSet)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if !countSet {
+		return nil
+	}
+
+	s, err = u.ScaleService(c, s, target, flagScaleCount)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Scaling service %s to %d task(s)\n", flagService, flagScaleCount)
+
+	return awaitServiceScaled(u, c, s, flagScaleCount, flagTimeout)
+}
+
+// updateScalableTarget applies the --min and --max flags and returns the updated target
+func updateScalableTarget(u *Outback.Outback, c *ecs.Cluster, s *ecs.Service, target *applicationautoscaling.ScalableTarget, minSet bool, maxSet bool) (*applicationautoscaling.ScalableTarget, error) {
+	var min, max *int64
+
+	if minSet {
+		min = aws.Int64(flagScaleMin)
+	}
+
+	if maxSet {
+		max = aws.Int64(flagScaleMax)
+	}
+
+	if err := u.UpdateScalableTarget(c, s, target, min, max); err != nil {
+		return nil, err
+	}
+
+	target, err := u.GetScalableTarget(c, s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Auto scaling capacity of service %s is now %d-%d\n", flagService, aws.Int64Value(target.MinCapacity), aws.Int64Value(target.MaxCapacity))
+
+	return target, nil
+}
+
+// awaitServiceScaled prints the running count of a service whenever it changes until the
+// service runs count tasks
+func awaitServiceScaled(u *Outback.Outback, c *ecs.Cluster, s *ecs.Service, count int64, timeout int) error {
+	progressCh := u.AwaitServiceScaled(c, s, count)
+	timeoutCh := time.After(time.Minute * time.Duration(timeout))
+	var running, pending int64 = -1, -1
+
+	for {
+		select {
+		case service, ok := <-progressCh:
+			if !ok {
+				fmt.Printf("Service %s is running %d task(s)\n", flagService, count)
+				return nil
+			}
+
+			if aws.Int64Value(service.RunningCount) != running || aws.Int64Value(service.PendingCount) != pending {
+				running, pending = aws.Int64Value(service.RunningCount), aws.Int64Value(service.PendingCount)
+				fmt.Printf("  running %d/%d, pending %d\n", running, count, pending)
+			}
+		case <-timeoutCh:
+			return ErrScaleTimeout
+		}
+	}
+}
+
+func init() {
+	serviceCmd.AddCommand(serviceScaleCmd)
+
+	serviceScaleCmd.Flags().Int64Var(&flagScaleCount, "count", 0, "Desired number of tasks")
+	serviceScaleCmd.Flags().Int64Var(&flagScaleMin, "min", 0, "Minimum capacity of the auto scaling target")
+	serviceScaleCmd.Flags().Int64Var(&flagScaleMax, "max", 0, "Maximum capacity of the auto scaling target")
+}
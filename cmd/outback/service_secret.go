@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var serviceSecretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage secrets stored in SSM Parameter Store or Secrets Manager",
+}
+
+func init() {
+	serviceCmd.AddCommand(serviceSecretCmd)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagServiceAddSecrets []string
+	flagSecretStore       string
+)
+
+var serviceAddSecretCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add/Update secrets",
+	Long: `At least one secret must be specified via the --secret flag. Specify
+	--secret with a key=value parameter multiple times to add multiple secrets. Values are
+	taken as they are, so they may contain commas.
+	Keys are kept as typed and must only contain letters, digits and underscores. The
+	--uppercase flag can be input to upper-case every key.
+	The value is written to SSM Parameter Store as a SecureString, or to Secrets Manager
+	with --store secretsmanager, and the container reads it through the ARN in its secrets.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is updated.
+	The task execution role of the service must be allowed to read the stored values.
+	The --dry-run flag can be input to print the changes without storing or applying them.`,
+	RunE: addSecret,
+}
+
+func addSecret(cmd *cobra.Command, args []string) error {
+	if flagSecretStore != Outback.SecretStoreSSM && flagSecretStore != Outback.SecretStoreSecretsManager {
+		return ErrInvalidSecretStore
+	}
+
+	parsedSecrets, err := stringsToKeyValue(flagServiceAddSecrets, flagEnvUppercase)
+
+	if err != nil {
+		return err
+	}
+
+	u := Outback.New(awsConfig)
+
+	c, err := u.GetCluster(flagCluster)
+
+	if err != nil {
+		return err
+	}
+
+	s, err := u.GetService(c, flagService)
+
+	if err != nil {
+		return err
+	}
+
+	t, err := u.GetTaskDefinition(c, s)
+
+	if err != nil {
+		return err
+	}
+
+	secrets := make([]*ecs.Secret, 0, len(parsedSecrets))
+
+	for _, kv := range parsedSecrets {
+		name := Outback.SecretName(flagSecretStore, flagCluster, flagService, aws.StringValue(kv.Name))
+		valueFrom := name
+
+		// a dry run does not store the value, so the plan shows the name it would be stored under
+		if !flagDryRun {
+			valueFrom, err = u.PutSecret(flagSecretStore, name, aws.StringValue(kv.Value))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		secrets = append(secrets, &ecs.Secret{Name: kv.Name, ValueFrom: aws.String(valueFrom)})
+	}
+
+	updatedDefinition, err := u.UpdateContainerDefinitionSecrets(*t, secrets, flagContainer, cfg.getRepo(flagCluster))
+
+	if err != nil {
+		return err
+	}
+
+	plan := u.NewPlan(c, s, t, &updatedDefinition, true)
+
+	if flagDryRun {
+		printPlan(plan)
+		return nil
+	}
+
+	_, err = u.ApplyPlan(plan)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Secret(s) " + strings.Join(secretNames(secrets), ", ") + " will be added")
+
+	return nil
+}
+
+func secretNames(secrets []*ecs.Secret) []string {
+	names := make([]string, len(secrets))
+	for i, secret := range secrets {
+		names[i] = aws.StringValue(secret.Name)
+	}
+	return names
+}
+
+func init() {
+	serviceSecretCmd.AddCommand(serviceAddSecretCmd)
+
+	serviceAddSecretCmd.Flags().StringArrayVarP(&flagServiceAddSecrets, "secret", "e", []string{}, "Secret to add e.g. key=value, repeat the flag for more secrets")
+	serviceAddSecretCmd.Flags().StringVar(&flagSecretStore, "store", Outback.SecretStoreSSM, "Where to store the values (ssm or secretsmanager)")
+	serviceAddSecretCmd.Flags().BoolVar(&flagEnvUppercase, "uppercase", false, "Upper-case every key")
+	serviceAddSecretCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to update")
+	serviceAddSecretCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without applying them")
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var serviceListSecretCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secrets and where they are stored",
+	Long: `Lists the secrets of every container of the service.
+	The --container flag can be input to list a single container by name.`,
+	RunE: listSecrets,
+}
+
+func listSecrets(cmd *cobra.Command, args []string) error {
+	cfgCluster, err := cfg.getCluster(flagCluster)
+	if err != nil {
+		return err
+	}
+
+	cfgService, err := cfg.getService(cfgCluster.Services, flagService)
+	if err != nil {
+		return err
+	}
+
+	outback := Outback.New(awsConfig)
+
+	c, err := outback.GetCluster(cfgCluster.Name)
+	if err != nil {
+		return err
+	}
+
+	s, err := outback.GetService(c, *cfgService)
+	if err != nil {
+		return err
+	}
+
+	t, err := outback.GetTaskDefinition(c, s)
+	if err != nil {
+		return err
+	}
+
+	containers, err := listedContainers(t)
+	if err != nil {
+		return err
+	}
+
+	printSecretTable(containers)
+
+	return nil
+}
+
+// printSecretTable prints the name, store and ARN of every secret, never the value
+func printSecretTable(containers []*ecs.ContainerDefinition) {
+	rows := make([][]string, 0)
+
+	for _, container := range containers {
+		for _, secret := range container.Secrets {
+			rows = append(rows, []string{
+				aws.StringValue(container.Name),
+				aws.StringValue(secret.Name),
+				Outback.SecretStore(aws.StringValue(secret.ValueFrom)),
+				aws.StringValue(secret.ValueFrom),
+			})
+		}
+	}
+
+	printTable([]string{"Container", "Name", "Store", "Value From"}, rows)
+}
+
+func init() {
+	serviceSecretCmd.AddCommand(serviceListSecretCmd)
+
+	serviceListSecretCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to list")
+}
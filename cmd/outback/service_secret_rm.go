@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagServiceRmSecrets []string
+	flagSecretPurge      bool
+)
+
+var serviceRmSecretCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Remove secrets",
+	Long: `At least one secret name must be specified via the --key flag. Specify
+	--key multiple times to remove multiple secrets.
+	The --container flag can be input to choose the container by name, by default the
+	container running an image from the configured repo is updated.
+	The stored values are kept so that earlier revisions can still be rolled back to. The
+	--purge flag can be input to also delete them from SSM Parameter Store or Secrets Manager.
+	The --dry-run flag can be input to print the changes without applying them.`,
+	RunE: rmSecret,
+}
+
+func rmSecret(cmd *cobra.Command, args []string) error {
+	u := Outback.New(awsConfig)
+
+	c, err := u.GetCluster(flagCluster)
+
+	if err != nil {
+		return err
+	}
+
+	s, err := u.GetService(c, flagService)
+
+	if err != nil {
+		return err
+	}
+
+	t, err := u.GetTaskDefinition(c, s)
+
+	if err != nil {
+		return err
+	}
+
+	newDefinition, removed, err := u.RemoveContainerDefinitionSecrets(*t, flagServiceRmSecrets, flagContainer, cfg.getRepo(flagCluster))
+
+	if err != nil {
+		return err
+	}
+
+	if len(removed) == 0 {
+		return ErrKeyNotPresent
+	}
+
+	plan := u.NewPlan(c, s, t, &newDefinition, true)
+
+	if flagDryRun {
+		printPlan(plan)
+		return nil
+	}
+
+	_, err = u.ApplyPlan(plan)
+
+	if err != nil {
+		return err
+	}
+
+	if flagSecretPurge {
+		for _, secret := range removed {
+			err = u.DeleteSecret(*secret.ValueFrom)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Println("The secret(s) " + strings.Join(secretNames(removed), ", ") + " will be removed from your task definition")
+
+	return nil
+}
+
+func init() {
+	serviceSecretCmd.AddCommand(serviceRmSecretCmd)
+
+	serviceRmSecretCmd.Flags().StringSliceVarP(&flagServiceRmSecrets, "key", "k", []string{}, "Secrets to remove e.g. DB_PASSWORD")
+	serviceRmSecretCmd.Flags().BoolVar(&flagSecretPurge, "purge", false, "Also delete the stored values")
+	serviceRmSecretCmd.Flags().StringVar(&flagContainer, "container", "", "Name of the container to update")
+	serviceRmSecretCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the planned changes without applying them")
+}
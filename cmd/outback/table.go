@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printTable prints rows in the same bordered table layout as the env and info tables,
+// sizing every column to its longest value
+func printTable(header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len(h)
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			if len(value) > widths[i] {
+				widths[i] = len(value)
+			}
+		}
+	}
+
+	dashes := make([]string, len(widths))
+	for i, w := range widths {
+		dashes[i] = strings.Repeat("-", w+2) // Adding two because of the table padding
+	}
+	border := fmt.Sprintf("+%s+", strings.Join(dashes, "+"))
+
+	printRow := func(row []string) {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			cells[i] = fmt.Sprintf(" %s%s ", value, strings.Repeat(" ", widths[i]-len(value)))
+		}
+		fmt.Printf("|%s|\n", strings.Join(cells, "|"))
+	}
+
+	fmt.Println(border)
+	printRow(header)
+	fmt.Println(border)
+	for _, row := range rows {
+		printRow(row)
+		fmt.Println(border)
+	}
+}
+
+// printJSON prints v as indented JSON
+func printJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+// validateOutput returns an error when the requested output format is not one of formats
+func validateOutput(output string, formats ...string) error {
+	for _, format := range formats {
+		if output == format {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s (expected one of %s)", ErrInvalidOutput, output, strings.Join(formats, ", "))
+}
@@ -2,14 +2,30 @@ package cmd
 
 import (
 	"fmt"
-	"regexp"
+	"strconv"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
 	Outback "github.com/koala-labs/outback/pkg/outback"
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagTaskCommand string
+	flagTaskCommand          string
+	flagTaskLaunchType       string
+	flagTaskCapacityProvider []string
+	flagTaskPlatformVersion  string
+	flagTaskSubnets          []string
+	flagTaskSecurityGroups   []string
+	flagTaskAssignPublicIP   string
+	flagTaskEntrypoint       string
+	flagTaskContainer        string
+	flagTaskEnv              []string
+	flagTaskCpu              string
+	flagTaskMemory           string
 )
 
 var taskRunCmd = &cobra.Command{
@@ -17,7 +33,15 @@ var taskRunCmd = &cobra.Command{
 	Short: "Run a one off tasks",
 	Long: `You must specify a cluster, service, and command to run. The command will use the image described in the task definition for the service that is specified. When specifying a command, the task definitions current command will be overriden with the one specified. 
 	There is also an option of creating command aliases in .outback/config.json. Once a command alias is in the outback config, specifying that alias via the --command flag will run the configured command.
-	If the awslogs driver is configured for the service in which you base your task. Logs for that task will be sent to cloudwatch under the same log group and prefix as described in the task definition.`,
+	If the awslogs driver is configured for the service in which you base your task. Logs for that task will be sent to cloudwatch under the same log group and prefix as described in the task definition. Otherwise the logs are not followed and the command only waits for the task to stop.
+	Once the task stops the exit code of every container and the reason the task stopped are printed. The command fails when an essential container exited with a non-zero code or never ran, so pipelines can gate deploys on the task.
+	The --timeout flag sets how many minutes to wait for the task to stop, after which the command fails with a timeout and leaves the task running.
+	The task is launched with the launch type or capacity provider strategy, platform version, subnets, security groups and public IP setting of the service, so it runs with the same networking as the service.
+	The --launch-type, --capacity-provider, --platform-version, --subnets, --security-groups and --assign-public-ip flags can be input to override each of them.
+	Commands are split into arguments with shell quoting rules, so quoted arguments are passed as one argument.
+	The --container flag can be input to choose the container by name, by default the first container runs the command.
+	The --entrypoint, --env, --cpu and --memory flags can be input to override the entrypoint and environment of the container and the size of the task. Overriding the entrypoint registers a copy of the task definition in the <family>-run family, which is deregistered once the task stops.
+	Each of these options can also be set on a command alias in the tasks of the config, the flags take precedence.`,
 	Run: runTask,
 }
 
@@ -30,20 +54,97 @@ func runTask(cmd *cobra.Command, args []string) {
 
 	handleError(err)
 
+	overrides, err := taskOverrides(cmd)
+
+	handleError(err)
+
+	err = run(cfgCluster.Name, *cfgService, overrides, flagTimeout, cmd)
+
+	handleError(err)
+}
+
+// taskOverrides returns the overrides of the task alias named by --command, or of the command
+// itself when it is not an alias, with the override flags that were input applied
+func taskOverrides(cmd *cobra.Command) (Outback.TaskOverrides, error) {
+	flags := cmd.Flags()
+
 	// Check if the command is available in the config as a shortcut
-	command, err := cfg.getCommand(flagTaskCommand)
+	task, err := cfg.getTask(flagTaskCommand)
 
 	// If the shortcut is not in the config, pass the command directly
 	if err != nil {
-		err = run(cfgCluster.Name, *cfgService, flagTaskCommand)
-	} else {
-		err = run(cfgCluster.Name, *cfgService, *command)
+		task = &Task{Command: flagTaskCommand}
 	}
 
-	handleError(err)
+	overrides, err := taskAliasOverrides(task)
+
+	if err != nil {
+		return overrides, err
+	}
+
+	if flags.Changed("container") {
+		overrides.Container = flagTaskContainer
+	}
+
+	if flags.Changed("entrypoint") {
+		overrides.Entrypoint = flagTaskEntrypoint
+	}
+
+	if flags.Changed("cpu") {
+		overrides.Cpu = flagTaskCpu
+	}
+
+	if flags.Changed("memory") {
+		overrides.Memory = flagTaskMemory
+	}
+
+	// variables of the --env flag replace those of the alias with the same key
+	flagEnv, err := stringsToKeyValue(flagTaskEnv, false)
+
+	if err != nil {
+		return overrides, err
+	}
+
+	for _, kv := range flagEnv {
+		replaced := false
+
+		for _, aliasKv := range overrides.Env {
+			if *aliasKv.Name == *kv.Name {
+				aliasKv.Value = kv.Value
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			overrides.Env = append(overrides.Env, kv)
+		}
+	}
+
+	return overrides, nil
+}
+
+// taskAliasOverrides returns the overrides configured on a task alias
+func taskAliasOverrides(task *Task) (Outback.TaskOverrides, error) {
+	overrides := Outback.TaskOverrides{
+		Container:  task.Container,
+		Command:    task.Command,
+		Entrypoint: task.Entrypoint,
+		Cpu:        task.Cpu,
+		Memory:     task.Memory,
+	}
+
+	env, err := stringsToKeyValue(task.Env, false)
+
+	if err != nil {
+		return overrides, err
+	}
+
+	overrides.Env = env
+
+	return overrides, nil
 }
 
-func run(cluster string, service string, command string) error {
+func run(cluster string, service string, overrides Outback.TaskOverrides, timeout int, cmd *cobra.Command) error {
 	outback := Outback.New(awsConfig)
 
 	c, err := outback.GetCluster(cluster)
@@ -58,70 +159,202 @@ func run(cluster string, service string, command string) error {
 		return err
 	}
 
-	t, err := outback.GetTaskDefinition(c, s)
+	serviceTaskDef, err := outback.GetTaskDefinition(c, s)
 
 	if err != nil {
 		return err
 	}
 
-	taskOutput, err := outback.RunTask(c, t, command)
+	launch, err := taskLaunch(s, cmd)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Running task on cluster %s with command %s\n", cluster, command)
+	fmt.Printf("Running task on cluster %s with command %s\n", cluster, overrides.Command)
 
-	td, err := outback.GetTaskDefinition(c, s)
+	return runAndWait(outback, c, serviceTaskDef, overrides, launch, service, timeout)
+}
+
+// runAndWait runs a one-off task based on a task definition, follows its logs until it stops
+// and prints the result. An error is returned when an essential container failed, or when the
+// task did not stop within timeout minutes.
+func runAndWait(outback *Outback.Outback, c *ecs.Cluster, serviceTaskDef *ecs.TaskDefinition, overrides Outback.TaskOverrides, launch *Outback.TaskLaunch, service string, timeout int) error {
+	t, err := outback.RunTaskDefinition(serviceTaskDef, overrides)
 
 	if err != nil {
 		return err
 	}
 
-	logGroup := td.ContainerDefinitions[0].LogConfiguration.Options["awslogs-group"]
-	logPrefix := td.ContainerDefinitions[0].LogConfiguration.Options["awslogs-stream-prefix"]
+	// a revision registered for an entrypoint override is only needed by this task
+	defer releaseRunTaskDefinition(outback, serviceTaskDef, aws.StringValue(t.TaskDefinitionArn))
 
-	o := &LogsOperation{
-		LogGroupName: *logGroup,
-		Filter:       "",
-		Follow:       true,
-		Namespace:    *logPrefix,
-		Service:      service,
+	taskOutput, err := outback.RunTask(c, t, overrides, launch)
+
+	if err != nil {
+		return err
 	}
 
-	r := regexp.MustCompile(`\/([A-Za-z0-9-]+)`)
-	taskID := r.FindStringSubmatch(*taskOutput.Tasks[0].TaskArn)[1]
+	i, err := Outback.TaskContainerIndex(t, overrides.Container)
 
-	o.AddTasks([]string{taskID})
-	o.AddStartTime("")
-	o.AddEndTime("")
+	if err != nil {
+		return err
+	}
+
+	taskArn := taskOutput.Tasks[0].TaskArn
+	o := taskLogsOperation(t.ContainerDefinitions[i], service)
 
 	waiting := make(chan error)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
 
 	go func() {
-		waiting <- outback.IsTaskRunning(c.ClusterArn, &taskID)
+		waiting <- outback.IsTaskRunning(c.ClusterArn, taskArn, time.Minute*time.Duration(timeout))
 	}()
 
-	go func() {
-		followLogs(o)
-	}()
+	if o != nil {
+		o.AddTasks([]string{taskID(*taskArn)})
+		o.AddStartTime("")
+		o.AddEndTime("")
 
-	loop := false
-	for !loop {
-		select {
-		case err := <-waiting:
-			if err != nil {
-				return err
-			}
-			loop = true
+		go func() {
+			followLogsUntil(o, stop)
+			close(stopped)
+		}()
+	} else {
+		fmt.Printf("Container %s does not send its logs to CloudWatch with an awslogs group and stream prefix, waiting for task %s to stop\n", aws.StringValue(t.ContainerDefinitions[i].Name), taskID(*taskArn))
+		close(stopped)
+	}
+
+	err = <-waiting
+
+	// print the last lines of the task before its result
+	close(stop)
+	<-stopped
+
+	// the task is still running, which says nothing about whether it fails
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == request.WaiterResourceNotReadyErrorCode {
+		return fmt.Errorf("%w: '%s'", ErrTaskTimeout, taskID(*taskArn))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tasks, err := outback.GetTasks(c, []*string{taskArn})
+
+	if err != nil {
+		return err
+	}
+
+	if len(tasks) == 0 {
+		return ErrTaskNotFound
+	}
+
+	printTaskResult(tasks[0])
+
+	return Outback.TaskFailure(tasks[0], t)
+}
+
+// releaseRunTaskDefinition deregisters a one-off revision that is no longer needed. A revision
+// that could not be deregistered is reported without failing the command.
+func releaseRunTaskDefinition(outback *Outback.Outback, serviceTaskDef *ecs.TaskDefinition, arn string) {
+	if err := outback.ReleaseRunTaskDefinition(serviceTaskDef, arn); err != nil {
+		fmt.Printf("Could not deregister task definition %s: %s\n", taskID(arn), err)
+	}
+}
+
+// taskLogsOperation returns the operation following the logs of a container, or nil when the
+// container does not send its logs to an awslogs group with a stream prefix
+func taskLogsOperation(container *ecs.ContainerDefinition, service string) *LogsOperation {
+	if container.LogConfiguration == nil {
+		return nil
+	}
+
+	logGroup := aws.StringValue(container.LogConfiguration.Options["awslogs-group"])
+	logPrefix := aws.StringValue(container.LogConfiguration.Options["awslogs-stream-prefix"])
+
+	if logGroup == "" || logPrefix == "" {
+		return nil
+	}
+
+	return &LogsOperation{
+		LogGroupName: logGroup,
+		Filter:       "",
+		Follow:       true,
+		Namespace:    logPrefix,
+		Service:      service,
+	}
+}
+
+// printTaskResult prints the exit code of every container of a stopped task and why it stopped
+func printTaskResult(task *ecs.Task) {
+	rows := make([][]string, len(task.Containers))
+
+	for i, container := range task.Containers {
+		exitCode := "-"
+		if container.ExitCode != nil {
+			exitCode = strconv.FormatInt(*container.ExitCode, 10)
+		}
+
+		rows[i] = []string{aws.StringValue(container.Name), exitCode, aws.StringValue(container.Reason)}
+	}
+
+	fmt.Printf("\nTask %s stopped: %s\n", taskID(aws.StringValue(task.TaskArn)), aws.StringValue(task.StoppedReason))
+	printTable([]string{"Container", "Exit Code", "Reason"}, rows)
+}
+
+// taskLaunch returns the launch settings of the service with the flags that were input applied
+func taskLaunch(s *ecs.Service, cmd *cobra.Command) (*Outback.TaskLaunch, error) {
+	flags := cmd.Flags()
+	launch := Outback.ServiceTaskLaunch(s)
+
+	if flags.Changed("launch-type") && flags.Changed("capacity-provider") {
+		return nil, ErrLaunchTypeAndCapacityProvider
+	}
+
+	if flags.Changed("launch-type") {
+		launch.SetLaunchType(flagTaskLaunchType)
+	}
+
+	if flags.Changed("capacity-provider") {
+		if err := launch.SetCapacityProviders(flagTaskCapacityProvider); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	if flags.Changed("platform-version") {
+		launch.PlatformVersion = flagTaskPlatformVersion
+	}
+
+	if flags.Changed("subnets") {
+		launch.Subnets = flagTaskSubnets
+	}
+
+	if flags.Changed("security-groups") {
+		launch.SecurityGroups = flagTaskSecurityGroups
+	}
+
+	if flags.Changed("assign-public-ip") {
+		launch.AssignPublicIP = flagTaskAssignPublicIP
+	}
+
+	return launch, nil
 }
 
 func init() {
 	taskCmd.AddCommand(taskRunCmd)
 
 	taskRunCmd.Flags().StringVarP(&flagTaskCommand, "command", "n", "", "name of the command to run from your config or the command itself")
+	taskRunCmd.Flags().StringVar(&flagTaskLaunchType, "launch-type", "", "Launch type of the task (FARGATE, EC2 or EXTERNAL), defaults to the service's")
+	taskRunCmd.Flags().StringSliceVar(&flagTaskCapacityProvider, "capacity-provider", []string{}, "Capacity provider of the task as name, name:weight or name:weight:base, defaults to the service's")
+	taskRunCmd.Flags().StringVar(&flagTaskPlatformVersion, "platform-version", "", "Fargate platform version of the task, defaults to the service's")
+	taskRunCmd.Flags().StringSliceVar(&flagTaskSubnets, "subnets", []string{}, "Subnets of the task, defaults to the service's")
+	taskRunCmd.Flags().StringSliceVar(&flagTaskSecurityGroups, "security-groups", []string{}, "Security groups of the task, defaults to the service's")
+	taskRunCmd.Flags().StringVar(&flagTaskContainer, "container", "", "Name of the container to run the command in, defaults to the first container")
+	taskRunCmd.Flags().StringVar(&flagTaskEntrypoint, "entrypoint", "", "Entrypoint of the container")
+	taskRunCmd.Flags().StringSliceVarP(&flagTaskEnv, "env", "e", []string{}, "Environment variables of the container e.g. key=value")
+	taskRunCmd.Flags().StringVar(&flagTaskCpu, "cpu", "", "CPU units of the task")
+	taskRunCmd.Flags().StringVar(&flagTaskMemory, "memory", "", "Memory of the task in MiB")
+	taskRunCmd.Flags().StringVar(&flagTaskAssignPublicIP, "assign-public-ip", "", "Whether the task gets a public IP (ENABLED or DISABLED), defaults to the service's")
 }
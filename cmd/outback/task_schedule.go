@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var flagTaskSchedule string
+
+var taskScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage tasks that run on a schedule",
+	Long: `Scheduled tasks are EventBridge rules that run a task alias of the config on a cron or
+	rate expression, e.g. "cron(0 3 * * ? *)" or "rate(1 hour)".
+	The task runs with the task definition, launch type and networking of the service of the
+	alias, or of the first service of the cluster when the alias has none. EventBridge runs the
+	task with the schedule-role of the config, which must be allowed to run the task and pass
+	its roles. Deploying the cluster points its scheduled tasks to the new task definition.`,
+}
+
+var taskListScheduleCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the scheduled tasks of a cluster",
+	RunE:  listSchedules,
+}
+
+func listSchedules(cmd *cobra.Command, args []string) error {
+	outback := Outback.New(awsConfig)
+
+	c, err := outback.GetCluster(flagCluster)
+	if err != nil {
+		return err
+	}
+
+	schedules, err := outback.ListSchedules(c)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(schedules))
+	for i, s := range schedules {
+		rows[i] = []string{s.Rule, s.Expression, s.State, taskID(s.TaskDefinition())}
+	}
+
+	printTable([]string{"Rule", "Schedule", "State", "Task Definition"}, rows)
+
+	return nil
+}
+
+// scheduleAlias returns the cluster of --cluster and the task alias named by --command
+func scheduleAlias() (*Cluster, *Task, error) {
+	cluster, err := cfg.getCluster(flagCluster)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	task, err := cfg.getTask(flagTaskCommand)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: '%s'", err, flagTaskCommand)
+	}
+
+	return cluster, task, nil
+}
+
+// scheduleExpression returns the --schedule flag when it was input, otherwise the schedule of
+// the task alias or the fallback
+func scheduleExpression(cmd *cobra.Command, task *Task, fallback string) (string, error) {
+	expression := fallback
+
+	if task.Schedule != "" {
+		expression = task.Schedule
+	}
+
+	if cmd.Flags().Changed("schedule") {
+		expression = flagTaskSchedule
+	}
+
+	if expression == "" {
+		return "", ErrNoSchedule
+	}
+
+	return expression, nil
+}
+
+// putSchedule points the schedule rule of a task alias to the current task definition and
+// networking of the service the alias runs as. The existing schedule is nil when the rule is
+// created.
+func putSchedule(outback *Outback.Outback, cluster *Cluster, task *Task, rule string, expression string, existing *Outback.Schedule) error {
+	role := cfg.getScheduleRole(cluster.Name)
+	if role == "" {
+		return ErrNoScheduleRole
+	}
+
+	service := task.Service
+	if service == "" && len(cluster.Services) > 0 {
+		service = cluster.Services[0]
+	}
+
+	if _, err := cfg.getService(cluster.Services, service); err != nil {
+		return fmt.Errorf("%w: '%s'", err, service)
+	}
+
+	c, err := outback.GetCluster(cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	s, err := outback.GetService(c, service)
+	if err != nil {
+		return err
+	}
+
+	serviceTaskDef, err := outback.GetTaskDefinition(c, s)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := taskAliasOverrides(task)
+	if err != nil {
+		return err
+	}
+
+	t, err := outback.RunTaskDefinition(serviceTaskDef, overrides)
+	if err != nil {
+		return err
+	}
+
+	target, err := outback.ScheduleTarget(c, t, overrides, Outback.ServiceTaskLaunch(s), role)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Runs the %s task of outback on cluster %s", task.Name, cluster.Name)
+
+	// keep a schedule that was disabled disabled
+	state := ""
+	if existing != nil {
+		state = existing.State
+	}
+
+	if err := outback.PutSchedule(rule, expression, state, description, target); err != nil {
+		return err
+	}
+
+	if existing != nil && existing.TaskDefinition() != aws.StringValue(t.TaskDefinitionArn) {
+		releaseRunTaskDefinition(outback, serviceTaskDef, existing.TaskDefinition())
+	}
+
+	return nil
+}
+
+func init() {
+	taskCmd.AddCommand(taskScheduleCmd)
+	taskScheduleCmd.AddCommand(taskListScheduleCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var taskCreateScheduleCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Run a task alias on a schedule",
+	Long: `Creates an EventBridge rule that runs the task alias named by --command on the cluster.
+	The --schedule flag can be input to set the cron or rate expression, by default the
+	schedule of the task alias in the config is used.`,
+	RunE: createSchedule,
+}
+
+func createSchedule(cmd *cobra.Command, args []string) error {
+	cluster, task, err := scheduleAlias()
+	if err != nil {
+		return err
+	}
+
+	expression, err := scheduleExpression(cmd, task, "")
+	if err != nil {
+		return err
+	}
+
+	outback := Outback.New(awsConfig)
+	rule := Outback.ScheduleRuleName(cluster.Name, task.Name)
+
+	existing, err := outback.GetSchedule(rule)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		return ErrScheduleExists
+	}
+
+	if err := putSchedule(outback, cluster, task, rule, expression, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Task %s will run on cluster %s on %s\n", task.Name, cluster.Name, expression)
+
+	return nil
+}
+
+func init() {
+	taskScheduleCmd.AddCommand(taskCreateScheduleCmd)
+
+	taskCreateScheduleCmd.Flags().StringVarP(&flagTaskCommand, "command", "n", "", "Name of the task alias from your config")
+	taskCreateScheduleCmd.Flags().StringVar(&flagTaskSchedule, "schedule", "", "Cron or rate expression e.g. \"rate(1 hour)\"")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var taskDeleteScheduleCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Stop running a task on a schedule",
+	Long: `Deletes the EventBridge rule of the task alias named by --command. Tasks that are
+	already running are not stopped.`,
+	RunE: deleteSchedule,
+}
+
+func deleteSchedule(cmd *cobra.Command, args []string) error {
+	cluster, err := cfg.getCluster(flagCluster)
+	if err != nil {
+		return err
+	}
+
+	outback := Outback.New(awsConfig)
+	rule := Outback.ScheduleRuleName(cluster.Name, flagTaskCommand)
+
+	existing, err := outback.GetSchedule(rule)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		return ErrScheduleNotFound
+	}
+
+	if err := outback.DeleteSchedule(rule); err != nil {
+		return err
+	}
+
+	fmt.Printf("Task %s will no longer run on cluster %s\n", flagTaskCommand, cluster.Name)
+
+	return nil
+}
+
+func init() {
+	taskScheduleCmd.AddCommand(taskDeleteScheduleCmd)
+
+	taskDeleteScheduleCmd.Flags().StringVarP(&flagTaskCommand, "command", "n", "", "Name of the task alias from your config")
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	Outback "github.com/koala-labs/outback/pkg/outback"
+	"github.com/spf13/cobra"
+)
+
+var taskUpdateScheduleCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update a scheduled task",
+	Long: `Updates the EventBridge rule of the task alias named by --command with the current
+	config of the alias and the task definition and networking of its service. The rule stays
+	enabled or disabled.
+	The --schedule flag can be input to change the cron or rate expression, by default the
+	schedule of the task alias in the config or else the current schedule is kept.`,
+	RunE: updateSchedule,
+}
+
+func updateSchedule(cmd *cobra.Command, args []string) error {
+	cluster, task, err := scheduleAlias()
+	if err != nil {
+		return err
+	}
+
+	outback := Outback.New(awsConfig)
+	rule := Outback.ScheduleRuleName(cluster.Name, task.Name)
+
+	existing, err := outback.GetSchedule(rule)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		return ErrScheduleNotFound
+	}
+
+	expression, err := scheduleExpression(cmd, task, existing.Expression)
+	if err != nil {
+		return err
+	}
+
+	if err := putSchedule(outback, cluster, task, rule, expression, existing); err != nil {
+		return err
+	}
+
+	fmt.Printf("Task %s will run on cluster %s on %s\n", task.Name, cluster.Name, expression)
+
+	return nil
+}
+
+func init() {
+	taskScheduleCmd.AddCommand(taskUpdateScheduleCmd)
+
+	taskUpdateScheduleCmd.Flags().StringVarP(&flagTaskCommand, "command", "n", "", "Name of the task alias from your config")
+	taskUpdateScheduleCmd.Flags().StringVar(&flagTaskSchedule, "schedule", "", "Cron or rate expression e.g. \"rate(1 hour)\"")
+}
@@ -3,7 +3,7 @@ module github.com/koala-labs/outback
 go 1.17
 
 require (
-	github.com/aws/aws-sdk-go v1.40.59
+	github.com/aws/aws-sdk-go v1.42.52
 	github.com/hashicorp/golang-lru v0.5.4
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/cobra v1.2.1
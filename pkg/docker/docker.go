@@ -58,6 +58,20 @@ func ImagePush(repo string, tag string) error {
 	return nil
 }
 
+// ImageTag tags an image from one repository so it can be pushed to another repository
+func ImageTag(fromRepo string, toRepo string, tag string) error {
+	source := fmt.Sprintf("%s:%s", fromRepo, tag)
+	target := fmt.Sprintf("%s:%s", toRepo, tag)
+
+	cmd := exec.Command("docker", "tag", source, target)
+
+	if err := term.PrintStdout(cmd); err != nil {
+		return ErrImageTag
+	}
+
+	return nil
+}
+
 // ImagePull pulls an image with a specific tag from the configured repository
 func ImagePull(repo string, tag string) error {
 	image := fmt.Sprintf("%s:%s", repo, tag)
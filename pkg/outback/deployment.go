@@ -7,8 +7,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/koala-labs/outback/pkg/docker"
+	"github.com/pkg/errors"
 )
 
 type Deployment struct {
@@ -21,6 +24,7 @@ type DeployDetail struct {
 	Cluster                  *ecs.Cluster
 	Service                  *ecs.Service
 	TaskDefinition           *ecs.TaskDefinition
+	PreviousTaskDefinition   *ecs.TaskDefinition
 	TaskDefinitionFamilyName string
 	RevisionNumber           int
 	Done                     bool
@@ -47,6 +51,10 @@ func (d *DeployDetail) SetTaskDefinition(taskDef *ecs.TaskDefinition) {
 	d.TaskDefinition = taskDef
 }
 
+func (d *DeployDetail) SetPreviousTaskDefinition(taskDef *ecs.TaskDefinition) {
+	d.PreviousTaskDefinition = taskDef
+}
+
 func (d *DeployDetail) SetDone(done bool) {
 	d.Done = done
 }
@@ -104,15 +112,27 @@ func (d *DeployDetail) TaskDefinitionFamily() string {
 	return r.FindString(*d.TaskDefinition.TaskDefinitionArn)
 }
 
+// Changed reports whether the service was moved off the task definition it was running
+// before the deployment started
+func (d *DeployDetail) Changed() bool {
+	if d.TaskDefinition == nil || d.PreviousTaskDefinition == nil {
+		return false
+	}
+
+	return *d.TaskDefinition.TaskDefinitionArn != *d.PreviousTaskDefinition.TaskDefinitionArn
+}
+
 func (u *Outback) NewDeployDetail() *DeployDetail {
 	return &DeployDetail{
 		Done: false,
 	}
 }
 
+// AwaitServicesRunning sends every detail of a deployment once its service is running. The
+// channel is buffered so waiters that are given up on, e.g. after a timeout, do not block.
 func (u *Outback) AwaitServicesRunning(deployment *Deployment) chan *DeployDetail {
 	waitTime := time.Second * 2
-	doneCh := make(chan *DeployDetail)
+	doneCh := make(chan *DeployDetail, len(deployment.DeployDetails))
 	for _, detail := range deployment.DeployDetails {
 		go func(detail *DeployDetail) {
 			for !detail.Done {
@@ -127,22 +147,85 @@ func (u *Outback) AwaitServicesRunning(deployment *Deployment) chan *DeployDetai
 	return doneCh
 }
 
-func (u *Outback) RollbackAll(deploy *Deployment, deployDetail *DeployDetail) <-chan error {
+// RollbackAll points every service in a deployment to the revision set in its own detail,
+// or to the previous deploy of its own task definition family when no revision is set. Every
+// target is resolved before any service is updated.
+func (u *Outback) RollbackAll(deploy *Deployment) <-chan error {
+	return u.applyAll(deploy, func(detail *DeployDetail) (*Plan, error) {
+		return u.PlanRollback(detail.Cluster, detail.Service, detail.TaskDefinition, detail.RevisionNumber)
+	})
+}
+
+// RollbackAllToCommit points every service in a deployment to the newest revision of its own
+// task definition family that was deployed with the given git commit. Every target revision
+// is resolved before any service is updated so a missing revision does not leave the
+// deployment half rolled back.
+func (u *Outback) RollbackAllToCommit(deploy *Deployment, commit string) <-chan error {
+	return u.applyAll(deploy, func(detail *DeployDetail) (*Plan, error) {
+		return u.PlanRollbackToCommit(detail.Cluster, detail.Service, detail.TaskDefinition, commit)
+	})
+}
+
+// applyAll plans a change for every service in a deployment and applies the plans once every
+// plan was computed, so a plan that fails does not leave the deployment half changed
+func (u *Outback) applyAll(deploy *Deployment, plan func(detail *DeployDetail) (*Plan, error)) <-chan error {
 	var wg sync.WaitGroup
-	errCh := make(chan error)
+	errCh := make(chan error, len(deploy.DeployDetails))
+
+	plans := make([]*Plan, len(deploy.DeployDetails))
+	for i, detail := range deploy.DeployDetails {
+		p, err := plan(detail)
+
+		if err != nil {
+			errCh <- err
+			close(errCh)
+			return errCh
+		}
+
+		plans[i] = p
+	}
+
+	wg.Add(len(deploy.DeployDetails))
+	for i, detail := range deploy.DeployDetails {
+		go func(detail *DeployDetail, p *Plan) {
+			defer wg.Done()
+
+			taskDef, err := u.ApplyPlan(p)
+
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			detail.SetTaskDefinition(taskDef)
+			detail.SetTaskDefinitionFamilyName(detail.TaskDefinitionFamily())
+		}(detail, plans[i])
+	}
+
+	wg.Wait()
+	close(errCh)
+	return errCh
+}
+
+// RegisterAll registers a task definition with the deployment's image for every service
+// without updating the services, so tasks can run the new revisions before the rollout
+func (u *Outback) RegisterAll(deploy *Deployment) <-chan error {
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(deploy.DeployDetails))
 
 	wg.Add(len(deploy.DeployDetails))
 	for _, detail := range deploy.DeployDetails {
 		go func(detail *DeployDetail) {
-			taskDefName, err := u.RollbackTaskDefinition(detail.Cluster, detail.Service, detail.TaskDefinition, deployDetail.RevisionNumber)
+			defer wg.Done()
+
+			taskDef, err := u.RegisterTaskDefinitionWithImage(detail.Cluster, detail.Service, deploy.BuildDetail.Repo, deploy.BuildDetail.CommitHash)
 
 			if err != nil {
 				errCh <- err
-				wg.Done()
+				return
 			}
-			detail.SetTaskDefinitionFamilyName(taskDefName)
 
-			wg.Done()
+			detail.SetTaskDefinition(taskDef)
 		}(detail)
 	}
 
@@ -151,24 +234,51 @@ func (u *Outback) RollbackAll(deploy *Deployment, deployDetail *DeployDetail) <-
 	return errCh
 }
 
-func (u *Outback) DeployAll(deploy *Deployment) <-chan error {
+// UpdateAll updates every service in a deployment to the task definition set in its detail
+func (u *Outback) UpdateAll(deploy *Deployment) <-chan error {
 	var wg sync.WaitGroup
-	errCh := make(chan error)
+	errCh := make(chan error, len(deploy.DeployDetails))
 
 	wg.Add(len(deploy.DeployDetails))
 	for _, detail := range deploy.DeployDetails {
 		go func(detail *DeployDetail) {
-			taskDef, err := u.UpdateServiceWithNewTaskDefinition(detail.Cluster, detail.Service, deploy.BuildDetail.Repo, deploy.BuildDetail.CommitHash)
+			defer wg.Done()
+
+			_, err := u.UpdateService(detail.Cluster, detail.Service, detail.TaskDefinition)
 
 			if err != nil {
-				fmt.Printf("Deployment failed: %s \n", err)
 				errCh <- err
-				wg.Done()
 			}
+		}(detail)
+	}
 
-			// Set TaskDefinition to the updated services new TaskDefinition
-			detail.SetTaskDefinition(taskDef)
-			wg.Done()
+	wg.Wait()
+	close(errCh)
+	return errCh
+}
+
+// DeregisterAll deregisters the task definitions RegisterAll registered for a deployment that
+// was abandoned before any service was updated, so they can never become a rollback target.
+// Every detail is pointed back to the task definition its service is running.
+func (u *Outback) DeregisterAll(deploy *Deployment) <-chan error {
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(deploy.DeployDetails))
+
+	for _, detail := range deploy.DeployDetails {
+		if !detail.Changed() {
+			continue
+		}
+
+		wg.Add(1)
+		go func(detail *DeployDetail) {
+			defer wg.Done()
+
+			if err := u.DeregisterTaskDefinition(detail.TaskDefinition); err != nil {
+				errCh <- err
+				return
+			}
+
+			detail.SetTaskDefinition(detail.PreviousTaskDefinition)
 		}(detail)
 	}
 
@@ -177,6 +287,99 @@ func (u *Outback) DeployAll(deploy *Deployment) <-chan error {
 	return errCh
 }
 
+// RestartAll forces a new deployment of every service in a deployment without changing its
+// task definition
+func (u *Outback) RestartAll(deploy *Deployment) <-chan error {
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(deploy.DeployDetails))
+
+	wg.Add(len(deploy.DeployDetails))
+	for _, detail := range deploy.DeployDetails {
+		go func(detail *DeployDetail) {
+			defer wg.Done()
+
+			s, err := u.RestartService(detail.Cluster, detail.Service)
+
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			// Keep the service with the restart deployment so it can be awaited
+			detail.SetService(s)
+			detail.SetDone(false)
+		}(detail)
+	}
+
+	wg.Wait()
+	close(errCh)
+	return errCh
+}
+
+// AwaitServicesRestarted waits until the tasks started by RestartAll are running and the old
+// tasks have drained. Like AwaitServicesRunning its channel is buffered so waiters that are
+// given up on do not block.
+func (u *Outback) AwaitServicesRestarted(deployment *Deployment) chan *DeployDetail {
+	waitTime := time.Second * 2
+	doneCh := make(chan *DeployDetail, len(deployment.DeployDetails))
+	for _, detail := range deployment.DeployDetails {
+		go func(detail *DeployDetail) {
+			for !detail.Done {
+				done := u.IsServiceRestarted(detail)
+				detail.SetDone(done)
+				time.Sleep(waitTime)
+			}
+			doneCh <- detail
+		}(detail)
+	}
+
+	return doneCh
+}
+
+// RevertAll points every service that a deployment moved to a new task definition back to
+// the task definition it was running before the deployment started. The returned deployment
+// only contains copies of the reverted details so it can be passed to AwaitServicesRunning
+// while waiters of the original deployment are still running.
+func (u *Outback) RevertAll(deploy *Deployment) (*Deployment, <-chan error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errCh := make(chan error, len(deploy.DeployDetails))
+	reverted := &Deployment{BuildDetail: deploy.BuildDetail}
+
+	for _, detail := range deploy.DeployDetails {
+		if !detail.Changed() {
+			continue
+		}
+
+		wg.Add(1)
+		go func(detail *DeployDetail) {
+			defer wg.Done()
+
+			_, err := u.UpdateService(detail.Cluster, detail.Service, detail.PreviousTaskDefinition)
+
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			revertedDetail := &DeployDetail{
+				Cluster:                detail.Cluster,
+				Service:                detail.Service,
+				TaskDefinition:         detail.PreviousTaskDefinition,
+				PreviousTaskDefinition: detail.PreviousTaskDefinition,
+			}
+
+			mu.Lock()
+			reverted.DeployDetails = append(reverted.DeployDetails, revertedDetail)
+			mu.Unlock()
+		}(detail)
+	}
+
+	wg.Wait()
+	close(errCh)
+	return reverted, errCh
+}
+
 func (u *Outback) LoginBuildPushImage(info BuildDetail) error {
 	var err error
 
@@ -218,3 +421,44 @@ func (u *Outback) LoginPullImage(repo string, tag string) error {
 
 	return nil
 }
+
+// PromoteImage makes an image tag from the source session's repo available in this session's
+// repo. The manifest is copied directly when the destination already has the image layers,
+// otherwise the image is pulled from the source and pushed to the destination with docker.
+func (u *Outback) PromoteImage(source *Outback, fromRepo string, toRepo string, tag string) error {
+	if fromRepo == toRepo {
+		return nil
+	}
+
+	err := u.CopyImage(source, fromRepo, toRepo, tag)
+
+	if err == nil {
+		return nil
+	}
+
+	if aerr, ok := errors.Cause(err).(awserr.Error); !ok || aerr.Code() != ecr.ErrCodeLayersNotFoundException {
+		return err
+	}
+
+	fmt.Printf("Image layers are missing from %s, copying the image with docker\n", toRepo)
+
+	err = source.LoginPullImage(fromRepo, tag)
+
+	if err != nil {
+		return err
+	}
+
+	err = docker.ImageTag(fromRepo, toRepo, tag)
+
+	if err != nil {
+		return err
+	}
+
+	err = u.ECRLogin()
+
+	if err != nil {
+		return err
+	}
+
+	return docker.ImagePush(toRepo, tag)
+}
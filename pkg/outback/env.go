@@ -0,0 +1,312 @@
+package outback
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+const (
+	EnvFormatDotenv = "dotenv"
+	EnvFormatJSON   = "json"
+)
+
+// MaskedEnvValue is shown in place of the value of a sensitive environment variable
+const MaskedEnvValue = "********"
+
+// DefaultSensitiveEnvPatterns are the key patterns whose values are masked when no patterns
+// are configured
+var DefaultSensitiveEnvPatterns = []string{"*_SECRET", "*_PASSWORD", "*_KEY", "*TOKEN*"}
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidEnvName reports whether a name is a portable environment variable name: letters, digits
+// and underscores, not starting with a digit
+func ValidEnvName(name string) bool {
+	return envNameRegexp.MatchString(name)
+}
+
+// SensitiveEnv reports whether a key matches one of the glob patterns, ignoring case
+func SensitiveEnv(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(name)); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MaskEnv returns a copy of an environment with the values of sensitive keys replaced by
+// MaskedEnvValue
+func MaskEnv(env []*ecs.KeyValuePair, patterns []string) []*ecs.KeyValuePair {
+	masked := make([]*ecs.KeyValuePair, len(env))
+
+	for i, kv := range env {
+		masked[i] = &ecs.KeyValuePair{Name: kv.Name, Value: kv.Value}
+
+		if SensitiveEnv(aws.StringValue(kv.Name), patterns) {
+			masked[i].Value = aws.String(MaskedEnvValue)
+		}
+	}
+
+	return masked
+}
+
+// IsDeployEnv reports whether an environment variable is one of the deploy markers set by
+// outback deploy, which are managed by outback and never imported or exported
+func IsDeployEnv(name string) bool {
+	return name == DEPLOY_TIME_ENV_VAR || name == DEPLOY_SHA_ENV_VAR
+}
+
+// ParseEnv reads an environment in the given format. Dotenv files hold one KEY=value per line,
+// blank lines and lines starting with # are skipped, an optional "export " prefix is allowed and
+// values may be single or double quoted. JSON files hold a single object of string values.
+func ParseEnv(r io.Reader, format string) ([]*ecs.KeyValuePair, error) {
+	switch format {
+	case EnvFormatDotenv:
+		return parseDotenv(r)
+	case EnvFormatJSON:
+		return parseEnvJSON(r)
+	}
+
+	return nil, fmt.Errorf("'%s' %s", format, errInvalidEnvFormat)
+}
+
+func parseDotenv(r io.Reader) ([]*ecs.KeyValuePair, error) {
+	env := make([]*ecs.KeyValuePair, 0)
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		text = strings.TrimPrefix(text, "export ")
+		split := strings.SplitN(text, "=", 2)
+
+		if len(split) != 2 || strings.TrimSpace(split[0]) == "" {
+			return nil, fmt.Errorf("line %d %s", line, errInvalidDotenvLine)
+		}
+
+		value, err := unquoteEnvValue(strings.TrimSpace(split[1]))
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d %s", line, errInvalidDotenvLine)
+		}
+
+		name := strings.TrimSpace(split[0])
+
+		if !ValidEnvName(name) {
+			return nil, fmt.Errorf("line %d '%s' %s", line, name, errInvalidEnvName)
+		}
+
+		if first, ok := seen[name]; ok {
+			return nil, fmt.Errorf("line %d '%s' %s on line %d", line, name, errDuplicateEnvName, first)
+		}
+		seen[name] = line
+
+		env = append(env, &ecs.KeyValuePair{
+			Name:  aws.String(name),
+			Value: aws.String(value),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, errCouldNotReadEnv)
+	}
+
+	return env, nil
+}
+
+func unquoteEnvValue(value string) (string, error) {
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		return strconv.Unquote(value)
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1], nil
+	}
+
+	return value, nil
+}
+
+func parseEnvJSON(r io.Reader) ([]*ecs.KeyValuePair, error) {
+	values := map[string]string{}
+
+	if err := json.NewDecoder(r).Decode(&values); err != nil {
+		return nil, errors.Wrap(err, errCouldNotReadEnv)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]*ecs.KeyValuePair, len(names))
+	for i, name := range names {
+		if !ValidEnvName(name) {
+			return nil, fmt.Errorf("'%s' %s", name, errInvalidEnvName)
+		}
+
+		env[i] = &ecs.KeyValuePair{Name: aws.String(name), Value: aws.String(values[name])}
+	}
+
+	return env, nil
+}
+
+// FormatEnv writes an environment sorted by name in the given format, leaving out the deploy
+// markers
+func FormatEnv(w io.Writer, env []*ecs.KeyValuePair, format string) error {
+	values := map[string]string{}
+	names := make([]string, 0, len(env))
+
+	for _, kv := range env {
+		name := aws.StringValue(kv.Name)
+		if IsDeployEnv(name) {
+			continue
+		}
+
+		values[name] = aws.StringValue(kv.Value)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch format {
+	case EnvFormatDotenv:
+		for _, name := range names {
+			if _, err := fmt.Fprintf(w, "%s=%s\n", name, quoteEnvValue(values[name])); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case EnvFormatJSON:
+		out, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	return fmt.Errorf("'%s' %s", format, errInvalidEnvFormat)
+}
+
+// quoteEnvValue double quotes values a dotenv parser would otherwise read differently
+func quoteEnvValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\r\"'#\\") {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// ContainerIndex returns the index of the container environment and secret changes apply
+// to: the container with the given name, or the first container running an image from repo
+// when no name is given
+func ContainerIndex(t *ecs.TaskDefinition, name string, repo string) (int, error) {
+	for i, container := range t.ContainerDefinitions {
+		if name != "" && aws.StringValue(container.Name) == name {
+			return i, nil
+		}
+
+		if name == "" && strings.Contains(aws.StringValue(container.Image), repo) {
+			return i, nil
+		}
+	}
+
+	if name != "" {
+		return 0, fmt.Errorf("'%s' %s", name, errContainerNotFound)
+	}
+
+	return 0, fmt.Errorf("'%s' %s", repo, errNoContainerForRepo)
+}
+
+// ContainerEnv returns the environment of the container selected by ContainerIndex
+func ContainerEnv(t *ecs.TaskDefinition, container string, repo string) ([]*ecs.KeyValuePair, error) {
+	i, err := ContainerIndex(t, container, repo)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ContainerDefinitions[i].Environment, nil
+}
+
+// UpdateContainerEnvVars adds or replaces environment variables of the container selected by
+// ContainerIndex
+func (u *Outback) UpdateContainerEnvVars(t ecs.TaskDefinition, updates []*ecs.KeyValuePair, container string, repo string) (ecs.TaskDefinition, error) {
+	t = *CopyTaskDefinition(&t)
+
+	i, err := ContainerIndex(&t, container, repo)
+
+	if err != nil {
+		return t, err
+	}
+
+	t.ContainerDefinitions[i].Environment = setEnvVars(t.ContainerDefinitions[i].Environment, updates)
+
+	return t, nil
+}
+
+// RemoveContainerEnvVars removes environment variables by name from the container selected by
+// ContainerIndex and returns the names that were removed
+func (u *Outback) RemoveContainerEnvVars(t ecs.TaskDefinition, names []string, container string, repo string) (ecs.TaskDefinition, []string, error) {
+	t = *CopyTaskDefinition(&t)
+
+	i, err := ContainerIndex(&t, container, repo)
+
+	if err != nil {
+		return t, nil, err
+	}
+
+	remove := map[string]bool{}
+	for _, name := range names {
+		remove[name] = true
+	}
+
+	removed := make([]string, 0)
+	kept := make([]*ecs.KeyValuePair, 0, len(t.ContainerDefinitions[i].Environment))
+
+	for _, kv := range t.ContainerDefinitions[i].Environment {
+		if remove[aws.StringValue(kv.Name)] {
+			removed = append(removed, aws.StringValue(kv.Name))
+		} else {
+			kept = append(kept, kv)
+		}
+	}
+
+	t.ContainerDefinitions[i].Environment = kept
+
+	return t, removed, nil
+}
+
+// setEnvVars replaces the values of existing variables and appends new ones
+func setEnvVars(env []*ecs.KeyValuePair, updates []*ecs.KeyValuePair) []*ecs.KeyValuePair {
+	for _, kv := range updates {
+		if i, ok := contains(env, kv); ok {
+			env[*i].Value = kv.Value
+		} else {
+			env = append(env, kv)
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,202 @@
+package outback
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// envFileHash matches the content hash EnvFileKey adds to the name of an environment file
+var envFileHash = regexp.MustCompile(`-[0-9a-f]{12}(\.[^./]*)?$`)
+
+// EnvFileKey returns the S3 key an environment file of a service is uploaded to. The key ends
+// with a hash of the contents, so every upload is a new object and the revisions referencing
+// earlier uploads keep reading the environment they were registered with.
+func EnvFileKey(cluster string, service string, name string, contents []byte) string {
+	sum := sha256.Sum256(contents)
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(path.Base(name), ext)
+
+	if ext == "" {
+		ext = ".env"
+	}
+
+	return fmt.Sprintf("outback/%s/%s/%s-%s%s", cluster, service, base, hex.EncodeToString(sum[:])[:12], ext)
+}
+
+// envFileSlot returns the bucket and key of an environment file uploaded by outback without its
+// content hash, which every upload of the same file shares. Files that were not uploaded by
+// outback have no slot.
+func envFileSlot(arn string) string {
+	bucket, key, err := ParseS3ObjectArn(arn)
+
+	if err != nil || !strings.HasPrefix(key, "outback/") || !envFileHash.MatchString(key) {
+		return ""
+	}
+
+	return bucket + "/" + envFileHash.ReplaceAllString(key, "$1")
+}
+
+// ParseEnvFile reads an environment file the way ECS does: one KEY=value per line, blank
+// lines and lines starting with # are skipped and values are taken as they are, without
+// removing quotes or an export prefix
+func ParseEnvFile(r io.Reader) ([]*ecs.KeyValuePair, error) {
+	env := make([]*ecs.KeyValuePair, 0)
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		split := strings.SplitN(text, "=", 2)
+
+		if len(split) != 2 {
+			return nil, fmt.Errorf("line %d %s", line, errInvalidDotenvLine)
+		}
+
+		name := split[0]
+
+		if !ValidEnvName(name) {
+			return nil, fmt.Errorf("line %d '%s' %s", line, name, errInvalidEnvName)
+		}
+
+		if first, ok := seen[name]; ok {
+			return nil, fmt.Errorf("line %d '%s' %s on line %d", line, name, errDuplicateEnvName, first)
+		}
+		seen[name] = line
+
+		env = append(env, &ecs.KeyValuePair{
+			Name:  aws.String(name),
+			Value: aws.String(split[1]),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, errCouldNotReadEnv)
+	}
+
+	return env, nil
+}
+
+// S3ObjectArn returns the ARN of an S3 object as used in the environmentFiles of a container
+func S3ObjectArn(bucket string, key string) string {
+	return fmt.Sprintf("arn:aws:s3:::%s/%s", bucket, key)
+}
+
+// ParseS3ObjectArn returns the bucket and key of an S3 object ARN as returned by S3ObjectArn
+func ParseS3ObjectArn(arn string) (string, string, error) {
+	object := strings.TrimPrefix(arn, "arn:aws:s3:::")
+	split := strings.SplitN(object, "/", 2)
+
+	if object == arn || len(split) != 2 || split[0] == "" || split[1] == "" {
+		return "", "", fmt.Errorf("'%s' %s", arn, errInvalidS3ObjectArn)
+	}
+
+	return split[0], split[1], nil
+}
+
+// UploadEnvFile validates an environment file with ParseEnvFile and uploads it to S3 with server
+// side encryption. It returns the ARN of the uploaded object.
+func (u *Outback) UploadEnvFile(bucket string, key string, contents []byte) (string, error) {
+	if _, err := ParseEnvFile(bytes.NewReader(contents)); err != nil {
+		return "", err
+	}
+
+	_, err := u.S3.PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(contents),
+		ContentType:          aws.String("text/plain"),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+	})
+
+	if err != nil {
+		return "", errors.Wrap(err, errCouldNotUploadEnvFile)
+	}
+
+	return S3ObjectArn(bucket, key), nil
+}
+
+// AttachContainerEnvFile adds an S3 environment file to the container selected by
+// ContainerIndex. An earlier upload of the same file by outback is replaced in place, so
+// uploads do not pile up. Attaching a file that is already attached changes nothing.
+func (u *Outback) AttachContainerEnvFile(t ecs.TaskDefinition, arn string, container string, repo string) (ecs.TaskDefinition, error) {
+	t = *CopyTaskDefinition(&t)
+
+	if _, _, err := ParseS3ObjectArn(arn); err != nil {
+		return t, err
+	}
+
+	i, err := ContainerIndex(&t, container, repo)
+
+	if err != nil {
+		return t, err
+	}
+
+	files := t.ContainerDefinitions[i].EnvironmentFiles
+	attached := &ecs.EnvironmentFile{
+		Type:  aws.String(ecs.EnvironmentFileTypeS3),
+		Value: aws.String(arn),
+	}
+
+	for _, file := range files {
+		if aws.StringValue(file.Value) == arn {
+			return t, nil
+		}
+	}
+
+	if slot := envFileSlot(arn); slot != "" {
+		for j, file := range files {
+			if envFileSlot(aws.StringValue(file.Value)) == slot {
+				files[j] = attached
+				return t, nil
+			}
+		}
+	}
+
+	t.ContainerDefinitions[i].EnvironmentFiles = append(files, attached)
+
+	return t, nil
+}
+
+// DetachContainerEnvFile removes an environment file from the container selected by
+// ContainerIndex and reports whether it was attached
+func (u *Outback) DetachContainerEnvFile(t ecs.TaskDefinition, arn string, container string, repo string) (ecs.TaskDefinition, bool, error) {
+	t = *CopyTaskDefinition(&t)
+
+	i, err := ContainerIndex(&t, container, repo)
+
+	if err != nil {
+		return t, false, err
+	}
+
+	files := t.ContainerDefinitions[i].EnvironmentFiles
+	kept := make([]*ecs.EnvironmentFile, 0, len(files))
+
+	for _, file := range files {
+		if aws.StringValue(file.Value) != arn {
+			kept = append(kept, file)
+		}
+	}
+
+	t.ContainerDefinitions[i].EnvironmentFiles = kept
+
+	return t, len(kept) != len(files), nil
+}
@@ -4,6 +4,10 @@ var (
 	errFailedToListClusters     = "error listing clusters"
 	errFailedToListServices     = "error listing services"
 	errFailedToListRunningTasks = "error listing running tasks"
+	errFailedToListTasks        = "error listing tasks"
+	errFailedToListSchedules    = "error listing schedules"
+
+	errFailedToListTaskDefinitions = "error listing task definitions"
 
 	errCouldNotRetrieveCluster        = "could not retrieve cluster"
 	errCouldNotRetrieveService        = "could not retrieve service"
@@ -12,9 +16,44 @@ var (
 	errCouldNotRetrieveImages         = "could not retrieve images"
 
 	errInvalidTaskDefinition = "task definition contains no container definitions"
+	errInvalidImage          = "is not a valid image, expected repo:tag"
+	errImageNotFound         = "image was not found in the repository"
+	errNoContainerForRepo    = "is not the image repo of any container in the task definition"
+	errContainerNotFound     = "is not the name of any container in the task definition"
+	errNoRevisionForCommit   = "was not deployed by any active revision of task definition family"
+	errNoRollbackCandidate   = "has no earlier active revision from a successful deploy to roll back to"
+	errInvalidSecretStore    = "is not a supported secret store, expected ssm or secretsmanager"
+	errInvalidEnvFormat      = "is not a supported environment format, expected dotenv or json"
+	errInvalidDotenvLine     = "is not in the form of KEY=value"
+	errCouldNotReadEnv       = "could not read environment"
+	errInvalidEnvName        = "is not a valid environment variable name, use letters, digits and underscores and do not start with a digit"
+	errDuplicateEnvName      = "was already set"
+	errInvalidS3ObjectArn    = "is not an S3 object ARN, expected arn:aws:s3:::bucket/key"
+
+	errInvalidScalableTarget      = "is greater than max"
+	errIncompleteScalableTarget   = "min and max capacity are both required to register a service as a scalable target"
+	errCountOutsideScalableTarget = "is outside of the auto scaling capacity range"
+	errInvalidCapacityProvider    = "is not a valid capacity provider, expected name, name:weight or name:weight:base"
+	errNoTaskSubnets              = "uses the awsvpc network mode but no subnets were given"
+	errScheduleTargetRejected     = "rejected the task target"
+	errScheduleHasNoTarget        = "has no task target"
+	errUnterminatedQuote          = "has an unterminated quote"
+	errUnterminatedEscape         = "ends with an unterminated escape"
+	errContainerDidNotRun         = "is an essential container that stopped without an exit code"
+	errContainerExitCode          = "is an essential container that exited with code"
 
 	errCouldNotRegisterTaskDefinition = "could not register new task definition"
 	errCouldNotUpdateService          = "could not update service"
+	errCouldNotCopyImage              = "could not copy image"
+	errCouldNotPutSecret              = "could not store secret"
+	errCouldNotDeleteSecret           = "could not delete secret"
+	errCouldNotUploadEnvFile          = "could not upload environment file"
+	errCouldNotUpdateScalableTarget   = "could not update scalable target"
+	errCouldNotRetrieveScalableTarget = "could not retrieve scalable target"
+	errCouldNotDeregister             = "could not deregister task definition"
+	errCouldNotRetrieveSchedule       = "could not retrieve schedule"
+	errCouldNotPutSchedule            = "could not put schedule"
+	errCouldNotDeleteSchedule         = "could not delete schedule"
 
 	errClusterNotFound = "cluster was not found"
 	errServiceNotFound = "service was not found"
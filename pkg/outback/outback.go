@@ -15,12 +15,22 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 	"github.com/pkg/errors"
 )
 
@@ -37,6 +47,11 @@ type Outback struct {
 	ECS    ecsiface.ECSAPI
 	ECR    ecriface.ECRAPI
 	CWL    cloudwatchlogsiface.CloudWatchLogsAPI
+	SSM    ssmiface.SSMAPI
+	SM     secretsmanageriface.SecretsManagerAPI
+	S3     s3iface.S3API
+	AAS    applicationautoscalingiface.ApplicationAutoScalingAPI
+	EB     eventbridgeiface.EventBridgeAPI
 }
 
 // New creates a Outback session and connects to AWS to create a session
@@ -68,6 +83,11 @@ func New(awsConfig *AwsConfig) *Outback {
 		ECS:    ecs.New(sess),
 		ECR:    ecr.New(sess),
 		CWL:    cloudwatchlogs.New(sess),
+		SSM:    ssm.New(sess),
+		SM:     secretsmanager.New(sess),
+		S3:     s3.New(sess),
+		AAS:    applicationautoscaling.New(sess),
+		EB:     eventbridge.New(sess),
 	}
 
 	return app
@@ -221,6 +241,106 @@ func (u *Outback) GetImages(t *ecs.TaskDefinition) ([]*ecr.ImageDetail, error) {
 	return images, nil
 }
 
+// GetImage returns the details of an image tag in a repository and fails when the tag does not exist
+func (u *Outback) GetImage(repo string, tag string) (*ecr.ImageDetail, error) {
+	input := &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(RepositoryName(repo)),
+		ImageIds: []*ecr.ImageIdentifier{{
+			ImageTag: aws.String(tag),
+		}},
+	}
+
+	if registryID := RegistryID(repo); registryID != "" {
+		input.SetRegistryId(registryID)
+	}
+
+	result, err := u.ECR.DescribeImages(input)
+
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeImageNotFoundException {
+			return nil, fmt.Errorf("'%s:%s' %s", repo, tag, errImageNotFound)
+		}
+
+		return nil, errors.Wrap(err, errCouldNotRetrieveImages)
+	}
+
+	if len(result.ImageDetails) < 1 {
+		return nil, fmt.Errorf("'%s:%s' %s", repo, tag, errImageNotFound)
+	}
+
+	return result.ImageDetails[0], nil
+}
+
+// CopyImage copies an image tag from a repo in the source session's registry to a repo in this
+// session's registry by putting its manifest. ECR only accepts the manifest when every layer of
+// the image already exists in the destination repository, otherwise a LayersNotFoundException
+// is returned as the cause of the error.
+func (u *Outback) CopyImage(source *Outback, fromRepo string, toRepo string, tag string) error {
+	getInput := &ecr.BatchGetImageInput{
+		RepositoryName: aws.String(RepositoryName(fromRepo)),
+		ImageIds: []*ecr.ImageIdentifier{{
+			ImageTag: aws.String(tag),
+		}},
+	}
+
+	if registryID := RegistryID(fromRepo); registryID != "" {
+		getInput.SetRegistryId(registryID)
+	}
+
+	images, err := source.ECR.BatchGetImage(getInput)
+
+	if err != nil {
+		return errors.Wrap(err, errCouldNotRetrieveImages)
+	}
+
+	if len(images.Images) < 1 {
+		return fmt.Errorf("'%s:%s' %s", fromRepo, tag, errImageNotFound)
+	}
+
+	putInput := &ecr.PutImageInput{
+		ImageManifest:          images.Images[0].ImageManifest,
+		ImageManifestMediaType: images.Images[0].ImageManifestMediaType,
+		ImageTag:               aws.String(tag),
+		RepositoryName:         aws.String(RepositoryName(toRepo)),
+	}
+
+	if registryID := RegistryID(toRepo); registryID != "" {
+		putInput.SetRegistryId(registryID)
+	}
+
+	_, err = u.ECR.PutImage(putInput)
+
+	if err != nil {
+		// the tag already points at this exact manifest in the destination
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeImageAlreadyExistsException {
+			return nil
+		}
+
+		return errors.Wrap(err, errCouldNotCopyImage)
+	}
+
+	return nil
+}
+
+// GetImageTag returns the image tag of the container whose image belongs to repo
+func (u *Outback) GetImageTag(t *ecs.TaskDefinition, repo string) (string, error) {
+	for _, container := range t.ContainerDefinitions {
+		if !strings.Contains(aws.StringValue(container.Image), repo) {
+			continue
+		}
+
+		_, tag, err := ParseImage(aws.StringValue(container.Image))
+
+		if err != nil {
+			return "", err
+		}
+
+		return tag, nil
+	}
+
+	return "", fmt.Errorf("'%s' %s", repo, errNoContainerForRepo)
+}
+
 // GetLastDeployedCommit finds the most recent committed image for a taskDefinition
 func (u *Outback) GetLastDeployedCommit(taskDefinition string) (string, error) {
 	result, err := u.ECS.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
@@ -251,6 +371,12 @@ func (u *Outback) RegisterTaskDefinitionWithImage(c *ecs.Cluster, s *ecs.Service
 		return nil, err
 	}
 
+	return u.RegisterTaskDefinitionWithEnvVars(u.TaskDefinitionWithImage(t, repo, tag))
+}
+
+// TaskDefinitionWithImage computes the task definition RegisterTaskDefinitionWithImage registers
+// without registering it. The provided task definition is not modified.
+func (u *Outback) TaskDefinitionWithImage(t *ecs.TaskDefinition, repo string, tag string) *ecs.TaskDefinition {
 	newTaskDef := u.UpdateTaskDefinitionImage(*t, repo, tag)
 
 	// track deploy time and deploy git commit sha as ENV variables in task definition
@@ -264,18 +390,29 @@ func (u *Outback) RegisterTaskDefinitionWithImage(c *ecs.Cluster, s *ecs.Service
 
 	newTaskDef = u.UpdateContainerDefinitionEnvVars(newTaskDef, deployInfo, repo)
 
-	result, err := u.ECS.RegisterTaskDefinition(&ecs.RegisterTaskDefinitionInput{
-		// Update the task definition to use the new docker image via UpdateTaskDefinitionImage
-		Cpu:                     newTaskDef.Cpu,
-		Family:                  newTaskDef.Family,
-		Memory:                  newTaskDef.Memory,
-		Volumes:                 newTaskDef.Volumes,
-		NetworkMode:             newTaskDef.NetworkMode,
-		ExecutionRoleArn:        newTaskDef.ExecutionRoleArn,
-		TaskRoleArn:             newTaskDef.TaskRoleArn,
-		ContainerDefinitions:    newTaskDef.ContainerDefinitions,
-		RequiresCompatibilities: newTaskDef.RequiresCompatibilities,
-	})
+	return &newTaskDef
+}
+
+// RegisterTaskDefinitionWithEnvVars takes a task definition as an argument and updates its
+// ContainerDefinitions field which contains environment variables
+func (u *Outback) RegisterTaskDefinitionWithEnvVars(t *ecs.TaskDefinition) (*ecs.TaskDefinition, error) {
+	var tags []*ecs.Tag
+
+	// tags are not part of the task definition itself and have to be requested separately
+	if t.TaskDefinitionArn != nil {
+		result, err := u.ECS.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+			TaskDefinition: t.TaskDefinitionArn,
+			Include:        aws.StringSlice([]string{ecs.TaskDefinitionFieldTags}),
+		})
+
+		if err != nil {
+			return nil, errors.Wrap(err, errCouldNotRetrieveTaskDefinition)
+		}
+
+		tags = result.Tags
+	}
+
+	result, err := u.ECS.RegisterTaskDefinition(cloneTaskDefinitionInput(t, tags))
 
 	if err != nil {
 		return nil, errors.Wrap(err, errCouldNotRegisterTaskDefinition)
@@ -284,59 +421,79 @@ func (u *Outback) RegisterTaskDefinitionWithImage(c *ecs.Cluster, s *ecs.Service
 	return result.TaskDefinition, nil
 }
 
-// RegisterTaskDefinitionWithEnvVars takes a task definition as an argument and updates its
-// ContainerDefinitions field which contains environment variables
-func (u *Outback) RegisterTaskDefinitionWithEnvVars(t *ecs.TaskDefinition) (*ecs.TaskDefinition, error) {
-	result, err := u.ECS.RegisterTaskDefinition(&ecs.RegisterTaskDefinitionInput{
+// DeregisterTaskDefinition marks a revision inactive so it is no longer listed as a revision of
+// its family. Tasks and services that already use the revision are not affected.
+func (u *Outback) DeregisterTaskDefinition(t *ecs.TaskDefinition) error {
+	_, err := u.ECS.DeregisterTaskDefinition(&ecs.DeregisterTaskDefinitionInput{
+		TaskDefinition: t.TaskDefinitionArn,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, errCouldNotDeregister)
+	}
+
+	return nil
+}
+
+// cloneTaskDefinitionInput copies every registrable field of a task definition into the input
+// used to register a new revision of it. Fields only set by ECS such as the revision, status
+// and ARN are left out.
+func cloneTaskDefinitionInput(t *ecs.TaskDefinition, tags []*ecs.Tag) *ecs.RegisterTaskDefinitionInput {
+	input := &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions:    t.ContainerDefinitions,
 		Cpu:                     t.Cpu,
+		EphemeralStorage:        t.EphemeralStorage,
+		ExecutionRoleArn:        t.ExecutionRoleArn,
 		Family:                  t.Family,
+		InferenceAccelerators:   t.InferenceAccelerators,
+		IpcMode:                 t.IpcMode,
 		Memory:                  t.Memory,
-		Volumes:                 t.Volumes,
 		NetworkMode:             t.NetworkMode,
-		ExecutionRoleArn:        t.ExecutionRoleArn,
-		TaskRoleArn:             t.TaskRoleArn,
-		ContainerDefinitions:    t.ContainerDefinitions,
+		PidMode:                 t.PidMode,
+		PlacementConstraints:    t.PlacementConstraints,
+		ProxyConfiguration:      t.ProxyConfiguration,
 		RequiresCompatibilities: t.RequiresCompatibilities,
-	})
+		RuntimePlatform:         t.RuntimePlatform,
+		TaskRoleArn:             t.TaskRoleArn,
+		Volumes:                 t.Volumes,
+	}
 
-	if err != nil {
-		return nil, errors.Wrap(err, errCouldNotRegisterTaskDefinition)
+	// registering with an empty tag list is rejected, so only set tags when there are any
+	if len(tags) > 0 {
+		input.Tags = tags
 	}
 
-	return result.TaskDefinition, nil
+	return input
 }
 
-// RollbackTaskDefinition updates the task definition to the desired revision number
-func (u *Outback) RollbackTaskDefinition(c *ecs.Cluster, s *ecs.Service, t *ecs.TaskDefinition, n int) (string, error) {
+// RollbackTarget returns the task definition a rollback should point a service to, which is
+// either the desired revision number of the current family or the previous deploy when n is 0
+func (u *Outback) RollbackTarget(s *ecs.Service, t *ecs.TaskDefinition, n int) (*ecs.TaskDefinition, error) {
+	if n == 0 {
+		return u.PreviousTaskDefinition(s, t)
+	}
 
-	var taskFamilyRevision string
+	family, _ := ParseTaskDefinitionArn(aws.StringValue(t.TaskDefinitionArn))
 
-	r := regexp.MustCompile(`([^\/]+)$`)
-	x := regexp.MustCompile(`([^\/]+)`)
+	return u.GetTaskDefinitionRevision(family, n)
+}
 
-	currentTaskDefinitionArn := *t.TaskDefinitionArn
-	currentTaskDefinitionFamilyRevision := r.FindString(currentTaskDefinitionArn)
-	currentTaskDefinitionArnName := x.FindString(currentTaskDefinitionArn)
-	split := strings.Split(currentTaskDefinitionFamilyRevision, ":")
-	taskFamily, taskRevision := split[0], split[1]
+// GetTaskDefinitionRevision describes a revision of a task definition family
+func (u *Outback) GetTaskDefinitionRevision(family string, revision int) (*ecs.TaskDefinition, error) {
+	result, err := u.ECS.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(fmt.Sprintf("%s:%d", family, revision)),
+	})
 
-	if n != 0 {
-		taskFamilyRevision = strings.Join([]string{taskFamily, ":", strconv.Itoa(n)}, "")
-	} else {
-		i, _ := strconv.Atoi(taskRevision)
-		i--
-		taskFamilyRevision = strings.Join([]string{taskFamily, ":", strconv.Itoa(i)}, "")
+	if err != nil {
+		return nil, errors.Wrap(err, errCouldNotRetrieveTaskDefinition)
 	}
 
-	taskFamilyRevisionArn := currentTaskDefinitionArnName + "/" + taskFamilyRevision
-	*t.TaskDefinitionArn = taskFamilyRevisionArn
-	_, err := u.RollbackService(c, s, taskFamilyRevision)
-
-	return taskFamilyRevision, err
+	return result.TaskDefinition, nil
 }
 
 // UpdateTaskDefinitionImage copies a task definition and updates its image tag
 func (u *Outback) UpdateTaskDefinitionImage(t ecs.TaskDefinition, repo string, tag string) ecs.TaskDefinition {
+	t = *CopyTaskDefinition(&t)
 	newImage := fmt.Sprintf("%s:%s", repo, tag)
 
 	// search for a ContainerDefinition that contains target repo url in the docker Image
@@ -352,21 +509,13 @@ func (u *Outback) UpdateTaskDefinitionImage(t ecs.TaskDefinition, repo string, t
 
 // UpdateContainerDefinitionEnvVars copies a task definition and updates the container definition environment
 func (u *Outback) UpdateContainerDefinitionEnvVars(t ecs.TaskDefinition, updates []*ecs.KeyValuePair, repo string) ecs.TaskDefinition {
+	t = *CopyTaskDefinition(&t)
+
 	// search for a ContainerDefinition that contains target repo url in the docker Image
 	// if none matches don't make any updates
 	for i, container := range t.ContainerDefinitions {
 		if strings.Contains(*container.Image, repo) {
-			currentEnv := t.ContainerDefinitions[i].Environment
-
-			for _, env := range updates {
-				if i, ok := contains(currentEnv, env); ok {
-					currentEnv[*i].Value = env.Value
-				} else {
-					currentEnv = append(currentEnv, env)
-				}
-			}
-
-			t.ContainerDefinitions[i].Environment = currentEnv
+			t.ContainerDefinitions[i].Environment = setEnvVars(t.ContainerDefinitions[i].Environment, updates)
 		}
 	}
 
@@ -376,7 +525,7 @@ func (u *Outback) UpdateContainerDefinitionEnvVars(t ecs.TaskDefinition, updates
 // contains is a helper to find a value in an ecs.KeyValuePair slice
 func contains(keyVals []*ecs.KeyValuePair, keyVal *ecs.KeyValuePair) (*int, bool) {
 	for i, kv := range keyVals {
-		if kv.Name == keyVal.Name {
+		if aws.StringValue(kv.Name) == aws.StringValue(keyVal.Name) {
 			return &i, true
 		}
 	}
@@ -392,19 +541,39 @@ func (u *Outback) GetRepoFromImage(image *string) string {
 	return repo
 }
 
-// RollbackService updates the ECS service with the desired rollback revision
-func (u *Outback) RollbackService(c *ecs.Cluster, s *ecs.Service, t string) (*ecs.UpdateServiceOutput, error) {
-	result, err := u.ECS.UpdateService(&ecs.UpdateServiceInput{
-		Cluster:        c.ClusterArn,
-		Service:        s.ServiceArn,
-		TaskDefinition: aws.String(t),
-	})
+// ParseImage splits an image URI into its repo and tag
+func ParseImage(image string) (string, string, error) {
+	i := strings.LastIndex(image, ":")
 
-	if err != nil {
-		return nil, errors.Wrap(err, errCouldNotUpdateService)
+	// a colon before the last slash belongs to a registry port, not a tag
+	if i < 0 || i < strings.LastIndex(image, "/") || i == len(image)-1 {
+		return "", "", fmt.Errorf("'%s' %s", image, errInvalidImage)
 	}
 
-	return result, nil
+	return image[:i], image[i+1:], nil
+}
+
+// RepositoryName returns the ECR repository name of a repo URI
+// e.g. 111222333444.dkr.ecr.us-east-1.amazonaws.com/org/app returns org/app
+func RepositoryName(repo string) string {
+	if i := strings.Index(repo, "/"); i >= 0 {
+		return repo[i+1:]
+	}
+
+	return repo
+}
+
+// RegistryID returns the AWS account id of an ECR repo URI, or an empty string when the
+// repo is not hosted on an ECR registry
+func RegistryID(repo string) string {
+	r := regexp.MustCompile(`^(\d{12})\.dkr\.ecr\.`)
+	match := r.FindStringSubmatch(repo)
+
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
 }
 
 // UpdateService updates a service in a cluster with a new task definition
@@ -422,43 +591,61 @@ func (u *Outback) UpdateService(c *ecs.Cluster, s *ecs.Service, t *ecs.TaskDefin
 	return result, nil
 }
 
-// UpdateServiceWithNewTaskDefinition registers a task definition with a tag and updates a service
-// with the newly registered task definition
-func (u *Outback) UpdateServiceWithNewTaskDefinition(c *ecs.Cluster, s *ecs.Service, repo string, tag string) (*ecs.TaskDefinition, error) {
-	t, err := u.RegisterTaskDefinitionWithImage(c, s, repo, tag)
+// RestartService forces a new deployment of a service with its current task definition, which
+// replaces every task and makes the new tasks read their secrets and environment files again
+func (u *Outback) RestartService(c *ecs.Cluster, s *ecs.Service) (*ecs.Service, error) {
+	result, err := u.ECS.UpdateService(&ecs.UpdateServiceInput{
+		Cluster:            c.ClusterArn,
+		Service:            s.ServiceArn,
+		ForceNewDeployment: aws.Bool(true),
+	})
 
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, errCouldNotUpdateService)
 	}
 
-	_, err = u.UpdateService(c, s, t)
+	return result.Service, nil
+}
+
+// RunTask runs a specified task in a cluster with the container overrides of a one-off task.
+// The launch settings, usually those of the service the task is based on, may be nil to use
+// the defaults of the cluster.
+func (u *Outback) RunTask(c *ecs.Cluster, t *ecs.TaskDefinition, overrides TaskOverrides, launch *TaskLaunch) (*ecs.RunTaskOutput, error) {
+	override, err := overrides.taskOverride(t)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return t, err
-}
-
-// RunTask runs a specified task in a cluster
-func (u *Outback) RunTask(c *ecs.Cluster, t *ecs.TaskDefinition, cmd string) (*ecs.RunTaskOutput, error) {
-	splitString := strings.Split(cmd, " ")
-
-	result, err := u.ECS.RunTask(&ecs.RunTaskInput{
+	in := &ecs.RunTaskInput{
 		Cluster:        c.ClusterName,
 		TaskDefinition: t.TaskDefinitionArn,
-		Overrides: &ecs.TaskOverride{
-			ContainerOverrides: []*ecs.ContainerOverride{{
-				Command: aws.StringSlice(splitString),
-				Name:    t.ContainerDefinitions[0].Name,
-			}},
-		},
-	})
+		Overrides:      override,
+	}
+
+	if launch != nil {
+		if err := launch.apply(in, t); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := u.ECS.RunTask(in)
 
 	if err != nil {
 		return nil, errors.Wrap(err, errCouldNotRunTask)
 	}
 
+	// ECS reports tasks it could not place, e.g. for lack of capacity, as failures
+	if len(result.Tasks) == 0 {
+		if len(result.Failures) == 0 {
+			return nil, errors.New(errCouldNotRunTask)
+		}
+
+		failure := result.Failures[0]
+
+		return nil, fmt.Errorf("%s: %s %s", errCouldNotRunTask, aws.StringValue(failure.Arn), aws.StringValue(failure.Reason))
+	}
+
 	return result, nil
 }
 
@@ -494,13 +681,54 @@ func (u *Outback) IsServiceRunning(detail *DeployDetail) bool {
 	return false
 }
 
-func (u *Outback) IsTaskRunning(cluster *string, task *string) error {
+// IsServiceRestarted reports whether the deployment RestartService started for a service is
+// the only deployment left and runs its desired count
+func (u *Outback) IsServiceRestarted(detail *DeployDetail) bool {
+	restart := PrimaryDeployment(detail.Service)
+
+	if restart == nil {
+		return false
+	}
+
+	s, err := u.GetService(detail.Cluster, aws.StringValue(detail.Service.ServiceName))
+
+	if err != nil {
+		return false
+	}
+
+	if len(s.Deployments) != 1 || aws.StringValue(s.Deployments[0].Id) != aws.StringValue(restart.Id) {
+		return false
+	}
+
+	return aws.Int64Value(s.Deployments[0].RunningCount) == aws.Int64Value(s.Deployments[0].DesiredCount)
+}
+
+// PrimaryDeployment returns the deployment of a service that new tasks are started for
+func PrimaryDeployment(s *ecs.Service) *ecs.Deployment {
+	for _, deployment := range s.Deployments {
+		if aws.StringValue(deployment.Status) == "PRIMARY" {
+			return deployment
+		}
+	}
+
+	return nil
+}
+
+// IsTaskRunning waits until a task stopped, checking every two seconds for at most timeout.
+// When the task is still running after the timeout an awserr.Error with the code
+// request.WaiterResourceNotReadyErrorCode is returned.
+func (u *Outback) IsTaskRunning(cluster *string, task *string, timeout time.Duration) error {
+	delay := time.Second * 2
+	attempts := int(timeout / delay)
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
 	err := u.ECS.WaitUntilTasksStoppedWithContext(aws.BackgroundContext(), &ecs.DescribeTasksInput{
 		Cluster: cluster,
 		Tasks:   []*string{task},
-	}, func(w *request.Waiter) {
-		w.Delay = request.ConstantWaiterDelay(time.Second * 2)
-	})
+	}, request.WithWaiterDelay(request.ConstantWaiterDelay(delay)), request.WithWaiterMaxAttempts(attempts))
 
 	return err
 }
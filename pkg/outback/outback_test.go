@@ -2,12 +2,19 @@ package outback
 
 import (
 	"fmt"
+	"io/ioutil"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/pkg/errors"
 
@@ -16,6 +23,14 @@ import (
 	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 )
 
 type mockedECRClient struct {
@@ -1065,7 +1080,8 @@ func TestOutbackRunTask(t *testing.T) {
 					Name: aws.String("test-container"),
 				}},
 			},
-			"echo this",
+			TaskOverrides{Command: "echo this"},
+			nil,
 		)
 
 		if err != nil {
@@ -1123,7 +1139,8 @@ func TestOutbackRunTaskError(t *testing.T) {
 					Name: aws.String("test-container"),
 				}},
 			},
-			"error",
+			TaskOverrides{Command: "error"},
+			nil,
 		)
 
 		if a, e := err, c.Expected; a.Error() != e.Error() {
@@ -1235,3 +1252,2843 @@ func TestOutbackUpdateContainerDefinitionEnvVars(t *testing.T) {
 		}
 	}
 }
+
+func TestOutbackRegisterAllError(t *testing.T) {
+	outback := Outback{
+		ECS: mockedDeploy{
+			DescribeTaskDefResp: &ecs.DescribeTaskDefinitionOutput{
+				TaskDefinition: &ecs.TaskDefinition{
+					TaskDefinitionArn: aws.String("family:1"),
+					ContainerDefinitions: []*ecs.ContainerDefinition{{
+						Image: aws.String("repo:abc"),
+					}},
+				},
+			},
+			RegisterTaskDefError: errors.New("test-error"),
+		},
+		ECR: mockedECRClient{},
+	}
+
+	previous := &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:1")}
+	detail := outback.NewDeployDetail()
+	detail.SetCluster(&ecs.Cluster{})
+	detail.SetService(&ecs.Service{})
+	detail.SetTaskDefinition(previous)
+	detail.SetPreviousTaskDefinition(previous)
+
+	deployment := &Deployment{DeployDetails: []*DeployDetail{detail}}
+	deployment.SetRepo("repo")
+	deployment.SetCommitHash("def")
+
+	errCount := 0
+	for range outback.RegisterAll(deployment) {
+		errCount++
+	}
+
+	if errCount != 1 {
+		t.Errorf("expected 1 error, got %d", errCount)
+	}
+
+	if detail.Changed() {
+		t.Errorf("expected failed deploy to leave the task definition unchanged")
+	}
+}
+
+func TestOutbackRevertAll(t *testing.T) {
+	outback := Outback{
+		ECS: mockedDeploy{UpdateServiceResp: &ecs.UpdateServiceOutput{}},
+		ECR: mockedECRClient{},
+	}
+
+	changed := &DeployDetail{
+		Cluster:                &ecs.Cluster{},
+		Service:                &ecs.Service{ServiceName: aws.String("changed")},
+		TaskDefinition:         &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:2")},
+		PreviousTaskDefinition: &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:1")},
+		Done:                   true,
+	}
+	unchanged := &DeployDetail{
+		Cluster:                &ecs.Cluster{},
+		Service:                &ecs.Service{ServiceName: aws.String("unchanged")},
+		TaskDefinition:         &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:1")},
+		PreviousTaskDefinition: &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:1")},
+	}
+
+	reverted, errCh := outback.RevertAll(&Deployment{DeployDetails: []*DeployDetail{changed, unchanged}})
+
+	for err := range errCh {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := len(reverted.DeployDetails), 1; a != e {
+		t.Fatalf("expected %d reverted services, got %d", e, a)
+	}
+
+	if a, e := *reverted.DeployDetails[0].Service.ServiceName, "changed"; a != e {
+		t.Errorf("expected %v service to be reverted, got %v", e, a)
+	}
+
+	revertedDetail := reverted.DeployDetails[0]
+
+	if a, e := *revertedDetail.TaskDefinition.TaskDefinitionArn, "family:1"; a != e {
+		t.Errorf("expected %v task definition, got %v", e, a)
+	}
+
+	if revertedDetail.Done {
+		t.Errorf("expected reverted service to be awaited again")
+	}
+
+	// waiters of the failed deployment may still be using the original detail
+	if a, e := *changed.TaskDefinition.TaskDefinitionArn, "family:2"; a != e || !changed.Done {
+		t.Errorf("expected the original detail to be unchanged, got %v", a)
+	}
+}
+
+func TestOutbackRevertAllError(t *testing.T) {
+	outback := Outback{
+		ECS: mockedDeploy{UpdateServiceError: errors.New("test-error")},
+		ECR: mockedECRClient{},
+	}
+
+	detail := &DeployDetail{
+		Cluster:                &ecs.Cluster{},
+		Service:                &ecs.Service{},
+		TaskDefinition:         &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:2")},
+		PreviousTaskDefinition: &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:1")},
+	}
+
+	reverted, errCh := outback.RevertAll(&Deployment{DeployDetails: []*DeployDetail{detail}})
+
+	expected := errors.Wrap(errors.New("test-error"), errCouldNotUpdateService)
+	for err := range errCh {
+		if a, e := err, expected; a.Error() != e.Error() {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+	}
+
+	if len(reverted.DeployDetails) != 0 {
+		t.Errorf("expected no reverted services, got %d", len(reverted.DeployDetails))
+	}
+}
+
+func TestOutbackUpdateContainerDefinitionEnvVarsExistingKey(t *testing.T) {
+	outback := Outback{
+		ECS: mockedRunTask{},
+		ECR: mockedECRClient{},
+	}
+
+	original := &ecs.TaskDefinition{
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:  aws.String("target-container"),
+			Image: aws.String("target-container:100"),
+			Environment: []*ecs.KeyValuePair{{
+				Name:  aws.String("KEY"),
+				Value: aws.String("old"),
+			}},
+		}},
+	}
+
+	result := outback.UpdateContainerDefinitionEnvVars(*original, []*ecs.KeyValuePair{{
+		Name:  aws.String("KEY"),
+		Value: aws.String("new"),
+	}}, "target-container")
+
+	if a, e := len(result.ContainerDefinitions[0].Environment), 1; a != e {
+		t.Fatalf("expected %d env vars, got %d", e, a)
+	}
+
+	if a, e := *result.ContainerDefinitions[0].Environment[0].Value, "new"; a != e {
+		t.Errorf("expected %v value, got %v", e, a)
+	}
+
+	if a, e := *original.ContainerDefinitions[0].Environment[0].Value, "old"; a != e {
+		t.Errorf("expected original task definition to keep %v, got %v", e, a)
+	}
+}
+
+func TestPlanChanges(t *testing.T) {
+	current := &ecs.TaskDefinition{
+		Family:            aws.String("family"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:111222333444:task-definition/family:3"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:  aws.String("app"),
+			Image: aws.String("repo:abc"),
+			Environment: []*ecs.KeyValuePair{
+				{Name: aws.String("CHANGED"), Value: aws.String("1")},
+				{Name: aws.String("REMOVED"), Value: aws.String("1")},
+				{Name: aws.String("SAME"), Value: aws.String("1")},
+			},
+		}},
+	}
+
+	desired := CopyTaskDefinition(current)
+	desired.ContainerDefinitions[0].Image = aws.String("repo:def")
+	desired.ContainerDefinitions[0].Environment = []*ecs.KeyValuePair{
+		{Name: aws.String("ADDED"), Value: aws.String("2")},
+		{Name: aws.String("CHANGED"), Value: aws.String("2")},
+		{Name: aws.String("SAME"), Value: aws.String("1")},
+	}
+
+	outback := Outback{ECS: mockedECSClient{}, ECR: mockedECRClient{}}
+	changes := outback.NewPlan(&ecs.Cluster{}, &ecs.Service{}, current, desired, true).Changes()
+
+	expected := []PlanChange{
+		{Field: "revision", Action: PlanActionChange, Before: "family:3", After: "family:(new)"},
+		{Container: "app", Field: "image", Action: PlanActionChange, Before: "repo:abc", After: "repo:def"},
+		{Container: "app", Field: "env ADDED", Action: PlanActionAdd, After: "2"},
+		{Container: "app", Field: "env CHANGED", Action: PlanActionChange, Before: "1", After: "2"},
+		{Container: "app", Field: "env REMOVED", Action: PlanActionRemove, Before: "1"},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v changes, got %v", expected, changes)
+	}
+
+	if a, e := *current.ContainerDefinitions[0].Image, "repo:abc"; a != e {
+		t.Errorf("expected current image to stay %v, got %v", e, a)
+	}
+}
+
+func TestPlanImage(t *testing.T) {
+	outback := Outback{
+		ECS: mockedDescribeTaskDefinition{Resp: &ecs.DescribeTaskDefinitionOutput{
+			TaskDefinition: &ecs.TaskDefinition{
+				Family:            aws.String("family"),
+				TaskDefinitionArn: aws.String("family:1"),
+				ContainerDefinitions: []*ecs.ContainerDefinition{{
+					Name:  aws.String("app"),
+					Image: aws.String("111222333444.dkr.ecr.us-west-1.amazonaws.com/repo:abc"),
+				}},
+			},
+		}},
+		ECR: mockedECRClient{},
+	}
+
+	plan, err := outback.PlanImage(&ecs.Cluster{}, &ecs.Service{}, "111222333444.dkr.ecr.us-west-1.amazonaws.com/repo", "def")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := *plan.Desired.ContainerDefinitions[0].Image, "111222333444.dkr.ecr.us-west-1.amazonaws.com/repo:def"; a != e {
+		t.Errorf("expected %v image, got %v", e, a)
+	}
+
+	if a, e := *plan.Current.ContainerDefinitions[0].Image, "111222333444.dkr.ecr.us-west-1.amazonaws.com/repo:abc"; a != e {
+		t.Errorf("expected current image to stay %v, got %v", e, a)
+	}
+
+	if !plan.Register {
+		t.Errorf("expected image plan to register a new revision")
+	}
+
+	env := keyValueMap(plan.Desired.ContainerDefinitions[0].Environment)
+	if a, e := env[DEPLOY_SHA_ENV_VAR], "def"; a != e {
+		t.Errorf("expected %v deploy sha, got %v", e, a)
+	}
+}
+
+func TestPlanRollback(t *testing.T) {
+	outback := Outback{
+		ECS: mockedDescribeTaskDefinition{Resp: &ecs.DescribeTaskDefinitionOutput{
+			TaskDefinition: &ecs.TaskDefinition{TaskDefinitionArn: aws.String("task-definition/family:4")},
+		}},
+		ECR: mockedECRClient{},
+	}
+
+	plan, err := outback.PlanRollback(&ecs.Cluster{}, &ecs.Service{}, &ecs.TaskDefinition{
+		TaskDefinitionArn: aws.String("task-definition/family:5"),
+	}, 4)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if plan.Register {
+		t.Errorf("expected rollback plan to reuse an existing revision")
+	}
+
+	changes := plan.Changes()
+	if a, e := changes[0].After, "family:4"; a != e {
+		t.Errorf("expected %v revision, got %v", e, a)
+	}
+}
+
+func TestApplyPlan(t *testing.T) {
+	outback := Outback{
+		ECS: mockedDeploy{
+			DescribeTaskDefResp: &ecs.DescribeTaskDefinitionOutput{
+				TaskDefinition: &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:1")},
+			},
+			RegisterTaskDefResp: &ecs.RegisterTaskDefinitionOutput{
+				TaskDefinition: &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:2")},
+			},
+			UpdateServiceResp: &ecs.UpdateServiceOutput{},
+		},
+		ECR: mockedECRClient{},
+	}
+
+	desired := &ecs.TaskDefinition{TaskDefinitionArn: aws.String("family:1")}
+
+	registered, err := outback.ApplyPlan(outback.NewPlan(&ecs.Cluster{}, &ecs.Service{}, desired, desired, true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := *registered.TaskDefinitionArn, "family:2"; a != e {
+		t.Errorf("expected %v task definition, got %v", e, a)
+	}
+
+	existing, err := outback.ApplyPlan(outback.NewPlan(&ecs.Cluster{}, &ecs.Service{}, desired, desired, false))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := *existing.TaskDefinitionArn, "family:1"; a != e {
+		t.Errorf("expected %v task definition, got %v", e, a)
+	}
+}
+
+type mockedRegisterTaskDefinitionWithTags struct {
+	ecsiface.ECSAPI
+	Tags  []*ecs.Tag
+	Input *ecs.RegisterTaskDefinitionInput
+}
+
+func (m *mockedRegisterTaskDefinitionWithTags) DescribeTaskDefinition(in *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{}, Tags: m.Tags}, nil
+}
+
+func (m *mockedRegisterTaskDefinitionWithTags) RegisterTaskDefinition(in *ecs.RegisterTaskDefinitionInput) (*ecs.RegisterTaskDefinitionOutput, error) {
+	m.Input = in
+	return &ecs.RegisterTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{}}, nil
+}
+
+// fullTaskDefinition returns a task definition with every field set
+func fullTaskDefinition() *ecs.TaskDefinition {
+	return &ecs.TaskDefinition{
+		Compatibilities: aws.StringSlice([]string{"FARGATE"}),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:  aws.String("app"),
+			Image: aws.String("repo:abc"),
+		}},
+		Cpu:              aws.String("256"),
+		DeregisteredAt:   aws.Time(time.Unix(2, 0)),
+		EphemeralStorage: &ecs.EphemeralStorage{SizeInGiB: aws.Int64(30)},
+		ExecutionRoleArn: aws.String("execution-role"),
+		Family:           aws.String("family"),
+		InferenceAccelerators: []*ecs.InferenceAccelerator{{
+			DeviceName: aws.String("device"),
+			DeviceType: aws.String("eia2.medium"),
+		}},
+		IpcMode:     aws.String(ecs.IpcModeTask),
+		Memory:      aws.String("512"),
+		NetworkMode: aws.String(ecs.NetworkModeAwsvpc),
+		PidMode:     aws.String(ecs.PidModeTask),
+		PlacementConstraints: []*ecs.TaskDefinitionPlacementConstraint{{
+			Expression: aws.String("attribute:ecs.availability-zone in [us-east-1a]"),
+			Type:       aws.String(ecs.TaskDefinitionPlacementConstraintTypeMemberOf),
+		}},
+		ProxyConfiguration: &ecs.ProxyConfiguration{
+			ContainerName: aws.String("envoy"),
+			Type:          aws.String(ecs.ProxyConfigurationTypeAppmesh),
+		},
+		RegisteredAt:            aws.Time(time.Unix(1, 0)),
+		RegisteredBy:            aws.String("user"),
+		RequiresAttributes:      []*ecs.Attribute{{Name: aws.String("attribute")}},
+		RequiresCompatibilities: aws.StringSlice([]string{"FARGATE"}),
+		Revision:                aws.Int64(3),
+		RuntimePlatform:         &ecs.RuntimePlatform{CpuArchitecture: aws.String(ecs.CPUArchitectureArm64)},
+		Status:                  aws.String(ecs.TaskDefinitionStatusActive),
+		TaskDefinitionArn:       aws.String("task-definition/family:3"),
+		TaskRoleArn:             aws.String("task-role"),
+		Volumes:                 []*ecs.Volume{{Name: aws.String("volume")}},
+	}
+}
+
+func TestCloneTaskDefinitionInputCopiesEveryField(t *testing.T) {
+	taskDef := fullTaskDefinition()
+	tags := []*ecs.Tag{{Key: aws.String("team"), Value: aws.String("koala")}}
+
+	input := cloneTaskDefinitionInput(taskDef, tags)
+
+	source := reflect.ValueOf(taskDef).Elem()
+	cloned := reflect.ValueOf(input).Elem()
+
+	for i := 0; i < cloned.NumField(); i++ {
+		field := cloned.Type().Field(i)
+
+		if field.PkgPath != "" || field.Name == "Tags" {
+			continue
+		}
+
+		sourceField := source.FieldByName(field.Name)
+
+		if !sourceField.IsValid() {
+			t.Errorf("task definition has no %s field to copy", field.Name)
+			continue
+		}
+
+		if sourceField.IsZero() {
+			t.Errorf("fullTaskDefinition does not set %s, add it so the round trip covers it", field.Name)
+			continue
+		}
+
+		if a, e := cloned.Field(i).Interface(), sourceField.Interface(); !reflect.DeepEqual(a, e) {
+			t.Errorf("expected %s to be %v, got %v", field.Name, e, a)
+		}
+	}
+
+	if !reflect.DeepEqual(input.Tags, tags) {
+		t.Errorf("expected %v tags, got %v", tags, input.Tags)
+	}
+
+	if err := input.Validate(); err != nil {
+		t.Errorf("expected a valid register input, got %v", err)
+	}
+}
+
+func TestCloneTaskDefinitionInputCopiesRuntimePlatform(t *testing.T) {
+	taskDef := &ecs.TaskDefinition{
+		Family: aws.String("family"),
+		RuntimePlatform: &ecs.RuntimePlatform{
+			CpuArchitecture:       aws.String(ecs.CPUArchitectureArm64),
+			OperatingSystemFamily: aws.String(ecs.OSFamilyWindowsServer2019Core),
+		},
+	}
+
+	input := cloneTaskDefinitionInput(taskDef, nil)
+
+	if !reflect.DeepEqual(input.RuntimePlatform, taskDef.RuntimePlatform) {
+		t.Errorf("expected %v runtime platform, got %v", taskDef.RuntimePlatform, input.RuntimePlatform)
+	}
+}
+
+func TestCloneTaskDefinitionInputWithoutTags(t *testing.T) {
+	input := cloneTaskDefinitionInput(fullTaskDefinition(), []*ecs.Tag{})
+
+	if input.Tags != nil {
+		t.Errorf("expected no tags, got %v", input.Tags)
+	}
+}
+
+func TestRegisterTaskDefinitionWithEnvVarsPreservesFields(t *testing.T) {
+	tags := []*ecs.Tag{{Key: aws.String("team"), Value: aws.String("koala")}}
+	mock := &mockedRegisterTaskDefinitionWithTags{Tags: tags}
+	outback := Outback{
+		ECS: mock,
+		ECR: mockedECRClient{},
+	}
+
+	taskDef := fullTaskDefinition()
+
+	_, err := outback.RegisterTaskDefinitionWithEnvVars(taskDef)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if e := cloneTaskDefinitionInput(taskDef, tags); !reflect.DeepEqual(mock.Input, e) {
+		t.Errorf("expected %v input, got %v", e, mock.Input)
+	}
+}
+
+func TestOutbackGetImage(t *testing.T) {
+	outback := Outback{
+		ECS: mockedECSClient{},
+		ECR: &mockedDescribeImages{Resp: &ecr.DescribeImagesOutput{
+			ImageDetails: []*ecr.ImageDetail{{
+				ImageTags: aws.StringSlice([]string{"abc"}),
+			}},
+		}},
+	}
+
+	image, err := outback.GetImage("111222333444.dkr.ecr.us-west-1.amazonaws.com/image", "abc")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := *image.ImageTags[0], "abc"; a != e {
+		t.Errorf("expected %v tag, got %v", e, a)
+	}
+}
+
+func TestOutbackGetImageError(t *testing.T) {
+	cases := []struct {
+		Resp     *ecr.DescribeImagesOutput
+		Error    error
+		Expected error
+	}{
+		{
+			Error:    awserr.New(ecr.ErrCodeImageNotFoundException, "not found", nil),
+			Expected: fmt.Errorf("'%s' %s", "repo:abc", errImageNotFound),
+		},
+		{
+			Resp:     &ecr.DescribeImagesOutput{},
+			Expected: fmt.Errorf("'%s' %s", "repo:abc", errImageNotFound),
+		},
+		{
+			Error:    errors.New("test-error"),
+			Expected: errors.Wrap(errors.New("test-error"), errCouldNotRetrieveImages),
+		},
+	}
+
+	for i, c := range cases {
+		outback := Outback{
+			ECS: mockedECSClient{},
+			ECR: &mockedDescribeImages{Resp: c.Resp, Error: c.Error},
+		}
+
+		_, err := outback.GetImage("repo", "abc")
+
+		if a, e := err, c.Expected; a.Error() != e.Error() {
+			t.Errorf("%d, expected %v, got %v", i, e, a)
+		}
+	}
+}
+
+func TestParseImage(t *testing.T) {
+	cases := []struct {
+		Image string
+		Repo  string
+		Tag   string
+		Error bool
+	}{
+		{Image: "111222333444.dkr.ecr.us-west-1.amazonaws.com/image:ea13366", Repo: "111222333444.dkr.ecr.us-west-1.amazonaws.com/image", Tag: "ea13366"},
+		{Image: "localhost:5000/org/image:v1", Repo: "localhost:5000/org/image", Tag: "v1"},
+		{Image: "localhost:5000/org/image", Error: true},
+		{Image: "image:", Error: true},
+		{Image: "image", Error: true},
+	}
+
+	for i, c := range cases {
+		repo, tag, err := ParseImage(c.Image)
+
+		if c.Error {
+			if err == nil {
+				t.Errorf("%d, expected an error for %v", i, c.Image)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%d, unexpected error %v", i, err)
+		}
+
+		if repo != c.Repo || tag != c.Tag {
+			t.Errorf("%d, expected %v and %v, got %v and %v", i, c.Repo, c.Tag, repo, tag)
+		}
+	}
+}
+
+func TestRepositoryNameAndRegistryID(t *testing.T) {
+	cases := []struct {
+		Repo       string
+		Name       string
+		RegistryID string
+	}{
+		{Repo: "111222333444.dkr.ecr.us-west-1.amazonaws.com/org/image", Name: "org/image", RegistryID: "111222333444"},
+		{Repo: "default.dkr.ecr.us-west-1.amazonaws.com/default", Name: "default", RegistryID: ""},
+		{Repo: "image", Name: "image", RegistryID: ""},
+	}
+
+	for i, c := range cases {
+		if a, e := RepositoryName(c.Repo), c.Name; a != e {
+			t.Errorf("%d, expected %v repository name, got %v", i, e, a)
+		}
+
+		if a, e := RegistryID(c.Repo), c.RegistryID; a != e {
+			t.Errorf("%d, expected %v registry id, got %v", i, e, a)
+		}
+	}
+}
+
+type mockedCopyImage struct {
+	ecriface.ECRAPI
+	BatchGetImageResp  *ecr.BatchGetImageOutput
+	BatchGetImageError error
+	PutImageError      error
+	PutImageInput      *ecr.PutImageInput
+}
+
+func (m *mockedCopyImage) BatchGetImage(in *ecr.BatchGetImageInput) (*ecr.BatchGetImageOutput, error) {
+	return m.BatchGetImageResp, m.BatchGetImageError
+}
+
+func (m *mockedCopyImage) PutImage(in *ecr.PutImageInput) (*ecr.PutImageOutput, error) {
+	m.PutImageInput = in
+	return &ecr.PutImageOutput{}, m.PutImageError
+}
+
+func TestOutbackCopyImage(t *testing.T) {
+	source := &Outback{
+		ECS: mockedECSClient{},
+		ECR: &mockedCopyImage{BatchGetImageResp: &ecr.BatchGetImageOutput{
+			Images: []*ecr.Image{{
+				ImageManifest:          aws.String("{}"),
+				ImageManifestMediaType: aws.String("application/vnd.docker.distribution.manifest.v2+json"),
+			}},
+		}},
+	}
+	destinationECR := &mockedCopyImage{}
+	destination := Outback{ECS: mockedECSClient{}, ECR: destinationECR}
+
+	err := destination.CopyImage(source, "111222333444.dkr.ecr.us-west-1.amazonaws.com/staging", "555666777888.dkr.ecr.us-east-1.amazonaws.com/prod", "abc")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	in := destinationECR.PutImageInput
+
+	if a, e := *in.RepositoryName, "prod"; a != e {
+		t.Errorf("expected %v repository, got %v", e, a)
+	}
+
+	if a, e := *in.RegistryId, "555666777888"; a != e {
+		t.Errorf("expected %v registry, got %v", e, a)
+	}
+
+	if a, e := *in.ImageTag, "abc"; a != e {
+		t.Errorf("expected %v tag, got %v", e, a)
+	}
+
+	if a, e := *in.ImageManifest, "{}"; a != e {
+		t.Errorf("expected %v manifest, got %v", e, a)
+	}
+}
+
+func TestOutbackCopyImageError(t *testing.T) {
+	manifest := &ecr.BatchGetImageOutput{Images: []*ecr.Image{{ImageManifest: aws.String("{}")}}}
+	cases := []struct {
+		Source   *mockedCopyImage
+		PutError error
+		Expected error
+	}{
+		{
+			Source:   &mockedCopyImage{BatchGetImageResp: &ecr.BatchGetImageOutput{}},
+			Expected: fmt.Errorf("'%s' %s", "staging:abc", errImageNotFound),
+		},
+		{
+			Source:   &mockedCopyImage{BatchGetImageError: errors.New("test-error")},
+			Expected: errors.Wrap(errors.New("test-error"), errCouldNotRetrieveImages),
+		},
+		{
+			Source:   &mockedCopyImage{BatchGetImageResp: manifest},
+			PutError: awserr.New(ecr.ErrCodeImageAlreadyExistsException, "exists", nil),
+			Expected: nil,
+		},
+		{
+			Source:   &mockedCopyImage{BatchGetImageResp: manifest},
+			PutError: errors.New("test-error"),
+			Expected: errors.Wrap(errors.New("test-error"), errCouldNotCopyImage),
+		},
+	}
+
+	for i, c := range cases {
+		source := &Outback{ECS: mockedECSClient{}, ECR: c.Source}
+		destination := Outback{ECS: mockedECSClient{}, ECR: &mockedCopyImage{PutImageError: c.PutError}}
+
+		err := destination.CopyImage(source, "staging", "prod", "abc")
+
+		if c.Expected == nil {
+			if err != nil {
+				t.Errorf("%d, unexpected error %v", i, err)
+			}
+			continue
+		}
+
+		if a, e := err, c.Expected; a == nil || a.Error() != e.Error() {
+			t.Errorf("%d, expected %v, got %v", i, e, a)
+		}
+	}
+}
+
+func TestOutbackPromoteImageSameRepo(t *testing.T) {
+	// no ECR calls are made, so a client without any methods must be enough
+	outback := Outback{ECS: mockedECSClient{}, ECR: mockedECRClient{}}
+
+	if err := outback.PromoteImage(&outback, "repo", "repo", "abc"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestOutbackGetImageTag(t *testing.T) {
+	outback := Outback{ECS: mockedECSClient{}, ECR: mockedECRClient{}}
+	taskDef := &ecs.TaskDefinition{
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Image: aws.String("envoy:v1.20"),
+		}, {
+			Image: aws.String("111222333444.dkr.ecr.us-west-1.amazonaws.com/app:ea13366"),
+		}},
+	}
+
+	tag, err := outback.GetImageTag(taskDef, "111222333444.dkr.ecr.us-west-1.amazonaws.com/app")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := tag, "ea13366"; a != e {
+		t.Errorf("expected %v tag, got %v", e, a)
+	}
+
+	_, err = outback.GetImageTag(taskDef, "other")
+
+	if a, e := err, fmt.Errorf("'%s' %s", "other", errNoContainerForRepo); a == nil || a.Error() != e.Error() {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+type mockedRevisions struct {
+	ecsiface.ECSAPI
+	Pages             [][]string
+	TaskDefinitions   map[string]*ecs.TaskDefinition
+	ListError         error
+	UpdateServiceArns []string
+	mu                sync.Mutex
+}
+
+func (m *mockedRevisions) ListTaskDefinitionsPages(in *ecs.ListTaskDefinitionsInput, fn func(*ecs.ListTaskDefinitionsOutput, bool) bool) error {
+	if m.ListError != nil {
+		return m.ListError
+	}
+
+	for i, page := range m.Pages {
+		if !fn(&ecs.ListTaskDefinitionsOutput{TaskDefinitionArns: aws.StringSlice(page)}, i == len(m.Pages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *mockedRevisions) DescribeTaskDefinition(in *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	t, ok := m.TaskDefinitions[*in.TaskDefinition]
+
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: t}, nil
+}
+
+func (m *mockedRevisions) UpdateService(in *ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.UpdateServiceArns = append(m.UpdateServiceArns, *in.TaskDefinition)
+	return &ecs.UpdateServiceOutput{}, nil
+}
+
+// deployedRevision returns a task definition revision carrying the deploy markers
+func deployedRevision(family string, revision int, commit string) *ecs.TaskDefinition {
+	return &ecs.TaskDefinition{
+		Family:            aws.String(family),
+		Revision:          aws.Int64(int64(revision)),
+		Status:            aws.String(ecs.TaskDefinitionStatusActive),
+		TaskDefinitionArn: aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:111222333444:task-definition/%s:%d", family, revision)),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:  aws.String("app"),
+			Image: aws.String("repo:" + commit),
+			Environment: []*ecs.KeyValuePair{
+				{Name: aws.String(DEPLOY_TIME_ENV_VAR), Value: aws.String("02 Jan 06 15:04 -0700")},
+				{Name: aws.String(DEPLOY_SHA_ENV_VAR), Value: aws.String(commit)},
+			},
+		}},
+	}
+}
+
+func revisionMock(revisions ...*ecs.TaskDefinition) *mockedRevisions {
+	m := &mockedRevisions{TaskDefinitions: map[string]*ecs.TaskDefinition{}}
+	page := []string{}
+
+	for _, t := range revisions {
+		m.TaskDefinitions[*t.TaskDefinitionArn] = t
+		m.TaskDefinitions[familyRevision(t)] = t
+		page = append(page, *t.TaskDefinitionArn)
+	}
+
+	m.Pages = [][]string{page}
+	return m
+}
+
+func TestOutbackTaskDefinitionRevisions(t *testing.T) {
+	outback := Outback{
+		ECS: &mockedRevisions{Pages: [][]string{
+			{"arn:aws:ecs:us-east-1:111222333444:task-definition/api:3", "arn:aws:ecs:us-east-1:111222333444:task-definition/api-worker:9"},
+			{"arn:aws:ecs:us-east-1:111222333444:task-definition/api:2"},
+		}},
+		ECR: mockedECRClient{},
+	}
+
+	revisions, err := outback.TaskDefinitionRevisions("api")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []string{"arn:aws:ecs:us-east-1:111222333444:task-definition/api:3", "arn:aws:ecs:us-east-1:111222333444:task-definition/api:2"}
+
+	if a, e := aws.StringValueSlice(revisions), expected; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v revisions, got %v", e, a)
+	}
+}
+
+func TestOutbackTaskDefinitionRevisionsError(t *testing.T) {
+	outback := Outback{
+		ECS: &mockedRevisions{ListError: errors.New("test-error")},
+		ECR: mockedECRClient{},
+	}
+
+	_, err := outback.TaskDefinitionRevisions("api")
+
+	if a, e := err, errors.Wrap(errors.New("test-error"), errFailedToListTaskDefinitions); a.Error() != e.Error() {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackFindTaskDefinitionByCommit(t *testing.T) {
+	outback := Outback{
+		ECS: revisionMock(
+			deployedRevision("api", 5, "v1"),
+			deployedRevision("api", 4, "bbbbbbbccccccc"),
+			deployedRevision("api", 3, "aaaaaaa"),
+			deployedRevision("api", 2, "aaaaaaa"),
+		),
+		ECR: mockedECRClient{},
+	}
+
+	cases := []struct {
+		Commit   string
+		Expected int64
+	}{
+		{Commit: "aaaaaaa", Expected: 3},
+		{Commit: "bbbbbbb", Expected: 4},
+		{Commit: "bbbbbbbccccccc", Expected: 4},
+		{Commit: "v1", Expected: 5},
+	}
+
+	for i, c := range cases {
+		taskDef, err := outback.FindTaskDefinitionByCommit("api", c.Commit)
+
+		if err != nil {
+			t.Fatalf("%d, unexpected error %v", i, err)
+		}
+
+		if a, e := *taskDef.Revision, c.Expected; a != e {
+			t.Errorf("%d, expected revision %v, got %v", i, e, a)
+		}
+	}
+
+	// too short to be an abbreviation, longer than the deployed commit, not deployed
+	for _, commit := range []string{"aaaa", "aaaaaaaa", "ccccccc"} {
+		_, err := outback.FindTaskDefinitionByCommit("api", commit)
+
+		if a, e := err, fmt.Errorf("'%s' %s '%s'", commit, errNoRevisionForCommit, "api"); a == nil || a.Error() != e.Error() {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+	}
+}
+
+func TestOutbackRollbackAllToCommit(t *testing.T) {
+	mock := revisionMock(
+		deployedRevision("api", 2, "bbbbbbb"),
+		deployedRevision("api", 1, "aaaaaaa"),
+		deployedRevision("worker", 7, "bbbbbbb"),
+		deployedRevision("worker", 6, "aaaaaaa"),
+	)
+	outback := Outback{ECS: mock, ECR: mockedECRClient{}}
+
+	api := &DeployDetail{Cluster: &ecs.Cluster{}, Service: &ecs.Service{}, TaskDefinition: deployedRevision("api", 2, "bbbbbbb")}
+	worker := &DeployDetail{Cluster: &ecs.Cluster{}, Service: &ecs.Service{}, TaskDefinition: deployedRevision("worker", 7, "bbbbbbb")}
+
+	for err := range outback.RollbackAllToCommit(&Deployment{DeployDetails: []*DeployDetail{api, worker}}, "aaaaaaa") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := api.TaskDefinitionFamilyName, "api:1"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := worker.TaskDefinitionFamilyName, "worker:6"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackRollbackAllToCommitMissingRevision(t *testing.T) {
+	mock := revisionMock(
+		deployedRevision("api", 2, "bbbbbbb"),
+		deployedRevision("api", 1, "aaaaaaa"),
+		deployedRevision("worker", 7, "bbbbbbb"),
+	)
+	outback := Outback{ECS: mock, ECR: mockedECRClient{}}
+
+	api := &DeployDetail{Cluster: &ecs.Cluster{}, Service: &ecs.Service{}, TaskDefinition: deployedRevision("api", 2, "bbbbbbb")}
+	worker := &DeployDetail{Cluster: &ecs.Cluster{}, Service: &ecs.Service{}, TaskDefinition: deployedRevision("worker", 7, "bbbbbbb")}
+
+	errCount := 0
+	for range outback.RollbackAllToCommit(&Deployment{DeployDetails: []*DeployDetail{api, worker}}, "aaaaaaa") {
+		errCount++
+	}
+
+	if errCount != 1 {
+		t.Errorf("expected 1 error, got %d", errCount)
+	}
+
+	if len(mock.UpdateServiceArns) != 0 {
+		t.Errorf("expected no service to be updated, got %v", mock.UpdateServiceArns)
+	}
+}
+
+func TestOutbackGetRevisionHistory(t *testing.T) {
+	envOnly := deployedRevision("api", 2, "aaaaaaa")
+	envOnly.ContainerDefinitions[0].Environment = append(envOnly.ContainerDefinitions[0].Environment, &ecs.KeyValuePair{
+		Name:  aws.String("FOO"),
+		Value: aws.String("bar"),
+	})
+	envOnly.TaskDefinitionArn = aws.String("arn:aws:ecs:us-east-1:111222333444:task-definition/api:3")
+	envOnly.Revision = aws.Int64(3)
+
+	outback := Outback{
+		ECS: revisionMock(
+			envOnly,
+			deployedRevision("api", 2, "aaaaaaa"),
+			deployedRevision("api", 1, "bbbbbbb"),
+		),
+		ECR: mockedECRClient{},
+	}
+
+	service := &ecs.Service{TaskDefinition: aws.String("arn:aws:ecs:us-east-1:111222333444:task-definition/api:2")}
+
+	history, err := outback.GetRevisionHistory(service, 2)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := len(history), 2; a != e {
+		t.Fatalf("expected %d revisions, got %d", e, a)
+	}
+
+	expected := []RevisionDetail{{
+		TaskDefinitionArn: "arn:aws:ecs:us-east-1:111222333444:task-definition/api:3",
+		Revision:          3,
+		Status:            ecs.TaskDefinitionStatusActive,
+		DeployTime:        "02 Jan 06 15:04 -0700",
+		DeployCommit:      "aaaaaaa",
+		Image:             "repo:aaaaaaa",
+		ImageTag:          "aaaaaaa",
+	}, {
+		TaskDefinitionArn: "arn:aws:ecs:us-east-1:111222333444:task-definition/api:2",
+		Revision:          2,
+		Status:            ecs.TaskDefinitionStatusActive,
+		DeployTime:        "02 Jan 06 15:04 -0700",
+		DeployCommit:      "aaaaaaa",
+		Image:             "repo:aaaaaaa",
+		ImageTag:          "aaaaaaa",
+		Current:           true,
+	}}
+
+	for i, e := range expected {
+		if a := *history[i]; a != e {
+			t.Errorf("%d, expected %v, got %v", i, e, a)
+		}
+	}
+}
+
+func TestParseTaskDefinitionArn(t *testing.T) {
+	cases := []struct {
+		Arn      string
+		Family   string
+		Revision int
+	}{
+		{Arn: "arn:aws:ecs:us-east-1:111222333444:task-definition/api:12", Family: "api", Revision: 12},
+		{Arn: "api:3", Family: "api", Revision: 3},
+		{Arn: "api", Family: "api", Revision: 0},
+	}
+
+	for i, c := range cases {
+		family, revision := ParseTaskDefinitionArn(c.Arn)
+
+		if family != c.Family || revision != c.Revision {
+			t.Errorf("%d, expected %v and %v, got %v and %v", i, c.Family, c.Revision, family, revision)
+		}
+	}
+}
+
+func TestOutbackPreviousTaskDefinition(t *testing.T) {
+	current := deployedRevision("api", 6, "ccccccc")
+
+	envOnly := deployedRevision("api", 5, "ccccccc")
+	envOnly.ContainerDefinitions[0].Environment = current.ContainerDefinitions[0].Environment
+
+	failed := deployedRevision("api", 4, "bbbbbbb")
+
+	manual := deployedRevision("api", 3, "")
+	manual.ContainerDefinitions[0].Environment = nil
+
+	previous := deployedRevision("api", 2, "aaaaaaa")
+	previous.ContainerDefinitions[0].Environment[0].Value = aws.String("01 Jan 06 15:04 -0700")
+
+	service := &ecs.Service{
+		Deployments: []*ecs.Deployment{{
+			TaskDefinition: current.TaskDefinitionArn,
+			RolloutState:   aws.String(ecs.DeploymentRolloutStateCompleted),
+			RunningCount:   aws.Int64(2),
+		}, {
+			TaskDefinition: failed.TaskDefinitionArn,
+			RolloutState:   aws.String(ecs.DeploymentRolloutStateFailed),
+		}},
+	}
+
+	outback := Outback{
+		ECS: revisionMock(current, envOnly, failed, manual, previous),
+		ECR: mockedECRClient{},
+	}
+
+	target, err := outback.PreviousTaskDefinition(service, current)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := *target.TaskDefinitionArn, *previous.TaskDefinitionArn; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// an env-only change of an earlier deploy is what the service ran before the current deploy
+	previousEnvChange := deployedRevision("api", 5, "bbbbbbb")
+	outback.ECS = revisionMock(current, previousEnvChange, deployedRevision("api", 4, "bbbbbbb"))
+
+	target, err = outback.PreviousTaskDefinition(&ecs.Service{}, current)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := *target.TaskDefinitionArn, *previousEnvChange.TaskDefinitionArn; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackPreviousTaskDefinitionFailedDeploymentDropped(t *testing.T) {
+	current := deployedRevision("api", 3, "ccccccc")
+	failed := deployedRevision("api", 2, "bbbbbbb")
+	previous := deployedRevision("api", 1, "aaaaaaa")
+
+	outback := Outback{
+		ECS: revisionMock(current, failed, previous),
+		ECR: mockedECRClient{},
+	}
+
+	// ECS no longer lists the failed deployment of revision 2 once revision 3 settled, so the
+	// failed revision cannot be told apart from a good one
+	service := &ecs.Service{
+		Deployments: []*ecs.Deployment{{
+			TaskDefinition: current.TaskDefinitionArn,
+			RolloutState:   aws.String(ecs.DeploymentRolloutStateCompleted),
+			RunningCount:   aws.Int64(2),
+		}},
+	}
+
+	target, err := outback.PreviousTaskDefinition(service, current)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := *target.TaskDefinitionArn, *failed.TaskDefinitionArn; a != e {
+		t.Errorf("expected the revision of the dropped deployment %v, got %v", e, a)
+	}
+}
+
+func TestOutbackPreviousTaskDefinitionNoCandidate(t *testing.T) {
+	current := deployedRevision("api", 3, "ccccccc")
+	envOnly := deployedRevision("api", 2, "ccccccc")
+	manual := deployedRevision("api", 1, "")
+	manual.ContainerDefinitions[0].Environment = nil
+
+	outback := Outback{
+		ECS: revisionMock(current, envOnly, manual),
+		ECR: mockedECRClient{},
+	}
+
+	_, err := outback.PreviousTaskDefinition(&ecs.Service{}, current)
+
+	if a, e := err, fmt.Errorf("'%s' %s", "api:3", errNoRollbackCandidate); a == nil || a.Error() != e.Error() {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackRollbackAll(t *testing.T) {
+	mock := revisionMock(
+		deployedRevision("api", 3, "bbbbbbb"),
+		deployedRevision("api", 2, "bbbbbbb"),
+		deployedRevision("api", 1, "aaaaaaa"),
+		deployedRevision("worker", 9, "bbbbbbb"),
+		deployedRevision("worker", 8, "aaaaaaa"),
+		deployedRevision("worker", 7, "aaaaaaa"),
+	)
+	outback := Outback{ECS: mock, ECR: mockedECRClient{}}
+
+	api := &DeployDetail{Cluster: &ecs.Cluster{}, Service: &ecs.Service{}, TaskDefinition: deployedRevision("api", 3, "bbbbbbb")}
+	worker := &DeployDetail{Cluster: &ecs.Cluster{}, Service: &ecs.Service{}, TaskDefinition: deployedRevision("worker", 9, "bbbbbbb")}
+	worker.SetRevisionNumber(7)
+
+	for err := range outback.RollbackAll(&Deployment{DeployDetails: []*DeployDetail{api, worker}}) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := api.TaskDefinitionFamilyName, "api:1"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := worker.TaskDefinitionFamilyName, "worker:7"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := len(mock.UpdateServiceArns), 2; a != e {
+		t.Errorf("expected %d services to be updated, got %v", e, mock.UpdateServiceArns)
+	}
+}
+
+func TestOutbackRollbackAllMissingRevision(t *testing.T) {
+	mock := revisionMock(
+		deployedRevision("api", 2, "bbbbbbb"),
+		deployedRevision("api", 1, "aaaaaaa"),
+		deployedRevision("worker", 7, "bbbbbbb"),
+	)
+	outback := Outback{ECS: mock, ECR: mockedECRClient{}}
+
+	api := &DeployDetail{Cluster: &ecs.Cluster{}, Service: &ecs.Service{}, TaskDefinition: deployedRevision("api", 2, "bbbbbbb")}
+	worker := &DeployDetail{Cluster: &ecs.Cluster{}, Service: &ecs.Service{}, TaskDefinition: deployedRevision("worker", 7, "bbbbbbb")}
+
+	errCount := 0
+	for range outback.RollbackAll(&Deployment{DeployDetails: []*DeployDetail{api, worker}}) {
+		errCount++
+	}
+
+	if errCount != 1 {
+		t.Errorf("expected 1 error, got %d", errCount)
+	}
+
+	if len(mock.UpdateServiceArns) != 0 {
+		t.Errorf("expected no service to be updated, got %v", mock.UpdateServiceArns)
+	}
+}
+
+// mockedSSM is an in memory parameter store
+type mockedSSM struct {
+	ssmiface.SSMAPI
+	Parameters map[string]*ssm.PutParameterInput
+}
+
+func (m *mockedSSM) PutParameter(in *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	m.Parameters[*in.Name] = in
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (m *mockedSSM) GetParameter(in *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	if _, ok := m.Parameters[*in.Name]; !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "not found", nil)
+	}
+
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{
+		Name: in.Name,
+		ARN:  aws.String("arn:aws:ssm:us-east-1:111222333444:parameter" + *in.Name),
+	}}, nil
+}
+
+func (m *mockedSSM) DeleteParameter(in *ssm.DeleteParameterInput) (*ssm.DeleteParameterOutput, error) {
+	if _, ok := m.Parameters[*in.Name]; !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "not found", nil)
+	}
+
+	delete(m.Parameters, *in.Name)
+	return &ssm.DeleteParameterOutput{}, nil
+}
+
+// mockedSecretsManager is an in memory secret store
+type mockedSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	Secrets map[string]string
+}
+
+func (m *mockedSecretsManager) arn(name string) string {
+	return "arn:aws:secretsmanager:us-east-1:111222333444:secret:" + name + "-AbCdEf"
+}
+
+func (m *mockedSecretsManager) CreateSecret(in *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	if _, ok := m.Secrets[*in.Name]; ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceExistsException, "exists", nil)
+	}
+
+	m.Secrets[*in.Name] = *in.SecretString
+	return &secretsmanager.CreateSecretOutput{ARN: aws.String(m.arn(*in.Name)), Name: in.Name}, nil
+}
+
+func (m *mockedSecretsManager) PutSecretValue(in *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	m.Secrets[*in.SecretId] = *in.SecretString
+	return &secretsmanager.PutSecretValueOutput{ARN: aws.String(m.arn(*in.SecretId)), Name: in.SecretId}, nil
+}
+
+func (m *mockedSecretsManager) DeleteSecret(in *secretsmanager.DeleteSecretInput) (*secretsmanager.DeleteSecretOutput, error) {
+	for name := range m.Secrets {
+		if m.arn(name) == *in.SecretId {
+			delete(m.Secrets, name)
+			return &secretsmanager.DeleteSecretOutput{}, nil
+		}
+	}
+
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+}
+
+func TestSecretName(t *testing.T) {
+	if a, e := SecretName(SecretStoreSSM, "dev", "api", "DB_PASSWORD"), "/outback/dev/api/DB_PASSWORD"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := SecretName(SecretStoreSecretsManager, "dev", "api", "DB_PASSWORD"), "outback/dev/api/DB_PASSWORD"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackPutAndDeleteSecretSSM(t *testing.T) {
+	mock := &mockedSSM{Parameters: map[string]*ssm.PutParameterInput{}}
+	outback := Outback{SSM: mock}
+
+	arn, err := outback.PutSecret(SecretStoreSSM, "/outback/dev/api/DB_PASSWORD", "hunter2")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := arn, "arn:aws:ssm:us-east-1:111222333444:parameter/outback/dev/api/DB_PASSWORD"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	param := mock.Parameters["/outback/dev/api/DB_PASSWORD"]
+	if a, e := *param.Type, ssm.ParameterTypeSecureString; a != e {
+		t.Errorf("expected %v parameter, got %v", e, a)
+	}
+
+	if err := outback.DeleteSecret(arn); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(mock.Parameters) != 0 {
+		t.Errorf("expected parameter to be deleted, got %v", mock.Parameters)
+	}
+}
+
+func TestOutbackPutAndDeleteSecretSecretsManager(t *testing.T) {
+	mock := &mockedSecretsManager{Secrets: map[string]string{"outback/dev/api/DB_PASSWORD": "old"}}
+	outback := Outback{SM: mock}
+
+	arn, err := outback.PutSecret(SecretStoreSecretsManager, "outback/dev/api/DB_PASSWORD", "hunter2")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := mock.Secrets["outback/dev/api/DB_PASSWORD"], "hunter2"; a != e {
+		t.Errorf("expected existing secret to be updated to %v, got %v", e, a)
+	}
+
+	if a, e := SecretStore(arn), SecretStoreSecretsManager; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if err := outback.DeleteSecret(arn); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(mock.Secrets) != 0 {
+		t.Errorf("expected secret to be deleted, got %v", mock.Secrets)
+	}
+}
+
+func TestOutbackPutSecretInvalidStore(t *testing.T) {
+	outback := Outback{}
+
+	_, err := outback.PutSecret("vault", "name", "value")
+
+	if a, e := err, fmt.Errorf("'%s' %s", "vault", errInvalidSecretStore); a == nil || a.Error() != e.Error() {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestParameterName(t *testing.T) {
+	cases := map[string]string{
+		"arn:aws:ssm:us-east-1:111222333444:parameter/outback/dev/api/KEY": "/outback/dev/api/KEY",
+		"arn:aws:ssm:us-east-1:111222333444:parameter/KEY":                 "KEY",
+		"/outback/dev/api/KEY": "/outback/dev/api/KEY",
+	}
+
+	for valueFrom, e := range cases {
+		if a := ParameterName(valueFrom); a != e {
+			t.Errorf("expected %v for %v, got %v", e, valueFrom, a)
+		}
+	}
+}
+
+func TestOutbackUpdateAndRemoveContainerDefinitionSecrets(t *testing.T) {
+	outback := Outback{}
+	taskDef := ecs.TaskDefinition{
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:    aws.String("sidecar"),
+			Image:   aws.String("nginx:latest"),
+			Secrets: []*ecs.Secret{},
+		}, {
+			Name:  aws.String("app"),
+			Image: aws.String("111222333444.dkr.ecr.us-east-1.amazonaws.com/api:abc"),
+			Secrets: []*ecs.Secret{
+				{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String("/outback/dev/api/DB_PASSWORD")},
+			},
+		}},
+	}
+
+	updated, err := outback.UpdateContainerDefinitionSecrets(taskDef, []*ecs.Secret{
+		{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String("arn:aws:ssm:us-east-1:111222333444:parameter/outback/dev/api/DB_PASSWORD")},
+		{Name: aws.String("API_KEY"), ValueFrom: aws.String("arn:aws:ssm:us-east-1:111222333444:parameter/outback/dev/api/API_KEY")},
+	}, "", "111222333444.dkr.ecr.us-east-1.amazonaws.com/api")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := len(updated.ContainerDefinitions[0].Secrets), 0; a != e {
+		t.Errorf("expected %d secrets on the sidecar, got %d", e, a)
+	}
+
+	if a, e := secretNames(updated.ContainerDefinitions[1].Secrets), []string{"DB_PASSWORD", "API_KEY"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := *taskDef.ContainerDefinitions[1].Secrets[0].ValueFrom, "/outback/dev/api/DB_PASSWORD"; a != e {
+		t.Errorf("expected the original task definition to be unchanged, got %v", a)
+	}
+
+	removed, secrets, err := outback.RemoveContainerDefinitionSecrets(updated, []string{"DB_PASSWORD"}, "app", "")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := secretNames(removed.ContainerDefinitions[1].Secrets), []string{"API_KEY"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := secretNames(secrets), []string{"DB_PASSWORD"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v to be removed, got %v", e, a)
+	}
+}
+
+func TestPlanChangesSecrets(t *testing.T) {
+	current := &ecs.TaskDefinition{
+		Family:            aws.String("api"),
+		TaskDefinitionArn: aws.String("task-definition/api:1"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:  aws.String("app"),
+			Image: aws.String("repo:abc"),
+			Secrets: []*ecs.Secret{
+				{Name: aws.String("OLD"), ValueFrom: aws.String("/outback/dev/api/OLD")},
+			},
+		}},
+	}
+
+	desired := CopyTaskDefinition(current)
+	desired.ContainerDefinitions[0].Secrets = []*ecs.Secret{
+		{Name: aws.String("NEW"), ValueFrom: aws.String("/outback/dev/api/NEW")},
+	}
+
+	changes := (&Plan{Current: current, Desired: desired, Register: true}).Changes()
+
+	expected := []PlanChange{
+		{Field: "revision", Action: PlanActionChange, Before: "api:1", After: "api:(new)"},
+		{Container: "app", Field: "secret NEW", Action: PlanActionAdd, After: "/outback/dev/api/NEW"},
+		{Container: "app", Field: "secret OLD", Action: PlanActionRemove, Before: "/outback/dev/api/OLD"},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v, got %v", expected, changes)
+	}
+}
+
+func secretNames(secrets []*ecs.Secret) []string {
+	names := make([]string, len(secrets))
+	for i, secret := range secrets {
+		names[i] = aws.StringValue(secret.Name)
+	}
+	return names
+}
+
+func TestParseEnvDotenv(t *testing.T) {
+	in := `# database
+DB_HOST=db.internal
+export DB_NAME = app
+
+GREETING="hello world\n"
+QUOTED='it''s'
+EMPTY=
+`
+
+	env, err := ParseEnv(strings.NewReader(in), EnvFormatDotenv)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := map[string]string{
+		"DB_HOST":  "db.internal",
+		"DB_NAME":  "app",
+		"GREETING": "hello world\n",
+		"QUOTED":   "it''s",
+		"EMPTY":    "",
+	}
+
+	if a, e := keyValueMap(env), expected; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestParseEnvDotenvInvalidLine(t *testing.T) {
+	_, err := ParseEnv(strings.NewReader("A=1\nnot a variable\n"), EnvFormatDotenv)
+
+	if a, e := err, fmt.Errorf("line %d %s", 2, errInvalidDotenvLine); a == nil || a.Error() != e.Error() {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestFormatEnvRoundTrip(t *testing.T) {
+	env := []*ecs.KeyValuePair{
+		{Name: aws.String("B"), Value: aws.String("two words")},
+		{Name: aws.String("A"), Value: aws.String("1")},
+		{Name: aws.String("C"), Value: aws.String(`say "hi" # not a comment`)},
+		{Name: aws.String(DEPLOY_SHA_ENV_VAR), Value: aws.String("abc123")},
+	}
+
+	for _, format := range []string{EnvFormatDotenv, EnvFormatJSON} {
+		var out strings.Builder
+
+		if err := FormatEnv(&out, env, format); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		parsed, err := ParseEnv(strings.NewReader(out.String()), format)
+
+		if err != nil {
+			t.Fatalf("unexpected error %v for %s", err, out.String())
+		}
+
+		expected := map[string]string{"A": "1", "B": "two words", "C": `say "hi" # not a comment`}
+
+		if a, e := keyValueMap(parsed), expected; !reflect.DeepEqual(a, e) {
+			t.Errorf("expected %v from %s, got %v", e, format, a)
+		}
+	}
+
+	var out strings.Builder
+	FormatEnv(&out, env, EnvFormatDotenv)
+
+	if a, e := out.String(), "A=1\nB=\"two words\"\nC=\"say \\\"hi\\\" # not a comment\"\n"; a != e {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+}
+
+func TestPlanHasChanges(t *testing.T) {
+	current := deployedRevision("api", 1, "aaaaaaa")
+
+	if (&Plan{Current: current, Desired: CopyTaskDefinition(current), Register: true}).HasChanges() {
+		t.Errorf("expected a plan registering an identical revision to have no changes")
+	}
+
+	desired := CopyTaskDefinition(current)
+	desired.ContainerDefinitions[0].Environment = append(desired.ContainerDefinitions[0].Environment,
+		&ecs.KeyValuePair{Name: aws.String("NEW"), Value: aws.String("1")})
+
+	if !(&Plan{Current: current, Desired: desired, Register: true}).HasChanges() {
+		t.Errorf("expected a plan adding an environment variable to have changes")
+	}
+}
+
+// sidecarTaskDefinition returns a task definition whose sidecar is listed before the app container
+func sidecarTaskDefinition() *ecs.TaskDefinition {
+	return &ecs.TaskDefinition{
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:  aws.String("proxy"),
+			Image: aws.String("envoyproxy/envoy:v1.20"),
+			Environment: []*ecs.KeyValuePair{
+				{Name: aws.String("LOG_LEVEL"), Value: aws.String("info")},
+			},
+		}, {
+			Name:  aws.String("app"),
+			Image: aws.String("111222333444.dkr.ecr.us-east-1.amazonaws.com/api:abc"),
+			Environment: []*ecs.KeyValuePair{
+				{Name: aws.String("LOG_LEVEL"), Value: aws.String("debug")},
+				{Name: aws.String("APP_ENV"), Value: aws.String("dev")},
+			},
+		}},
+	}
+}
+
+func TestContainerIndex(t *testing.T) {
+	taskDef := sidecarTaskDefinition()
+	repo := "111222333444.dkr.ecr.us-east-1.amazonaws.com/api"
+
+	cases := []struct {
+		name string
+		repo string
+		e    int
+	}{
+		{"", repo, 1},
+		{"proxy", repo, 0},
+		{"app", "", 1},
+	}
+
+	for _, c := range cases {
+		i, err := ContainerIndex(taskDef, c.name, c.repo)
+
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		if i != c.e {
+			t.Errorf("expected container %d for %q, got %d", c.e, c.name, i)
+		}
+	}
+
+	if _, err := ContainerIndex(taskDef, "worker", repo); err == nil || err.Error() != fmt.Sprintf("'worker' %s", errContainerNotFound) {
+		t.Errorf("expected container not found error, got %v", err)
+	}
+
+	if _, err := ContainerIndex(taskDef, "", "other-repo"); err == nil || err.Error() != fmt.Sprintf("'other-repo' %s", errNoContainerForRepo) {
+		t.Errorf("expected no container for repo error, got %v", err)
+	}
+}
+
+func TestOutbackEnvVarsTargetTheSameContainer(t *testing.T) {
+	outback := Outback{}
+	taskDef := sidecarTaskDefinition()
+	repo := "111222333444.dkr.ecr.us-east-1.amazonaws.com/api"
+
+	updated, err := outback.UpdateContainerEnvVars(*taskDef, []*ecs.KeyValuePair{
+		{Name: aws.String("LOG_LEVEL"), Value: aws.String("warn")},
+	}, "", repo)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	removed, names, err := outback.RemoveContainerEnvVars(updated, []string{"LOG_LEVEL"}, "", repo)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := names, []string{"LOG_LEVEL"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v to be removed, got %v", e, a)
+	}
+
+	if a, e := keyValueMap(removed.ContainerDefinitions[1].Environment), map[string]string{"APP_ENV": "dev"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected app environment %v, got %v", e, a)
+	}
+
+	if a, e := keyValueMap(removed.ContainerDefinitions[0].Environment), map[string]string{"LOG_LEVEL": "info"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected the sidecar to be unchanged, got %v", a)
+	}
+
+	sidecar, err := outback.UpdateContainerEnvVars(*taskDef, []*ecs.KeyValuePair{
+		{Name: aws.String("LOG_LEVEL"), Value: aws.String("warn")},
+	}, "proxy", repo)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := keyValueMap(sidecar.ContainerDefinitions[0].Environment)["LOG_LEVEL"], "warn"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := keyValueMap(taskDef.ContainerDefinitions[0].Environment)["LOG_LEVEL"], "info"; a != e {
+		t.Errorf("expected the original task definition to be unchanged, got %v", a)
+	}
+}
+
+func TestValidEnvName(t *testing.T) {
+	for _, name := range []string{"APP_ENV", "app_env", "DbHost", "_PRIVATE", "V2"} {
+		if !ValidEnvName(name) {
+			t.Errorf("expected %q to be valid", name)
+		}
+	}
+
+	for _, name := range []string{"", "2FA", "APP-ENV", "APP ENV", "app.env", "KEY=VALUE"} {
+		if ValidEnvName(name) {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestParseEnvDotenvInvalidNames(t *testing.T) {
+	cases := map[string]string{
+		"A=1\nmy-key=2\n": fmt.Sprintf("line 2 'my-key' %s", errInvalidEnvName),
+		"A=1\nB=2\nA=3\n": fmt.Sprintf("line 3 'A' %s on line 1", errDuplicateEnvName),
+	}
+
+	for in, e := range cases {
+		_, err := ParseEnv(strings.NewReader(in), EnvFormatDotenv)
+
+		if err == nil || err.Error() != e {
+			t.Errorf("expected %v, got %v", e, err)
+		}
+	}
+
+	env, err := ParseEnv(strings.NewReader("lower_case=1\nMixedCase=2\n"), EnvFormatDotenv)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := keyValueMap(env), map[string]string{"lower_case": "1", "MixedCase": "2"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected keys to keep their casing %v, got %v", e, a)
+	}
+}
+
+func TestParseEnvJSONInvalidName(t *testing.T) {
+	_, err := ParseEnv(strings.NewReader(`{"GOOD": "1", "not valid": "2"}`), EnvFormatJSON)
+
+	if a, e := err, fmt.Errorf("'%s' %s", "not valid", errInvalidEnvName); a == nil || a.Error() != e.Error() {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestEnvDiff(t *testing.T) {
+	staging := deployedRevision("api", 12, "aaaaaaa").ContainerDefinitions[0]
+	staging.Environment = append(staging.Environment,
+		&ecs.KeyValuePair{Name: aws.String("APP_ENV"), Value: aws.String("staging")},
+		&ecs.KeyValuePair{Name: aws.String("DEBUG"), Value: aws.String("true")},
+		&ecs.KeyValuePair{Name: aws.String("LOG_LEVEL"), Value: aws.String("info")},
+	)
+	staging.Secrets = []*ecs.Secret{{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String("/outback/staging/api/DB_PASSWORD")}}
+
+	prod := deployedRevision("api", 40, "bbbbbbb").ContainerDefinitions[0]
+	prod.Environment = append(prod.Environment,
+		&ecs.KeyValuePair{Name: aws.String("APP_ENV"), Value: aws.String("prod")},
+		&ecs.KeyValuePair{Name: aws.String("LOG_LEVEL"), Value: aws.String("info")},
+		&ecs.KeyValuePair{Name: aws.String("CDN_URL"), Value: aws.String("https://cdn.example.com")},
+	)
+	prod.Secrets = []*ecs.Secret{{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String("/outback/prod/api/DB_PASSWORD")}}
+
+	expected := []PlanChange{
+		{Field: "env APP_ENV", Action: PlanActionChange, Before: "staging", After: "prod"},
+		{Field: "env CDN_URL", Action: PlanActionAdd, After: "https://cdn.example.com"},
+		{Field: "env DEBUG", Action: PlanActionRemove, Before: "true"},
+		{Field: "secret DB_PASSWORD", Action: PlanActionChange, Before: "/outback/staging/api/DB_PASSWORD", After: "/outback/prod/api/DB_PASSWORD"},
+	}
+
+	if a, e := EnvDiff(staging, prod), expected; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestSensitiveEnv(t *testing.T) {
+	for _, name := range []string{"STRIPE_SECRET", "DB_PASSWORD", "api_key", "GITHUB_TOKEN", "TOKEN_URL", "session_token_ttl"} {
+		if !SensitiveEnv(name, DefaultSensitiveEnvPatterns) {
+			t.Errorf("expected %q to be sensitive", name)
+		}
+	}
+
+	for _, name := range []string{"APP_ENV", "KEYBOARD", "SECRET_NAME", "PASSWORD_MIN_LENGTH"} {
+		if SensitiveEnv(name, DefaultSensitiveEnvPatterns) {
+			t.Errorf("expected %q not to be sensitive", name)
+		}
+	}
+
+	if !SensitiveEnv("DATABASE_URL", []string{"DATABASE_URL"}) {
+		t.Errorf("expected configured patterns to be used")
+	}
+}
+
+func TestMaskEnv(t *testing.T) {
+	env := []*ecs.KeyValuePair{
+		{Name: aws.String("APP_ENV"), Value: aws.String("prod")},
+		{Name: aws.String("DB_PASSWORD"), Value: aws.String("hunter2")},
+	}
+
+	masked := MaskEnv(env, DefaultSensitiveEnvPatterns)
+
+	if a, e := keyValueMap(masked), map[string]string{"APP_ENV": "prod", "DB_PASSWORD": MaskedEnvValue}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := *env[1].Value, "hunter2"; a != e {
+		t.Errorf("expected the original environment to be unchanged, got %v", a)
+	}
+
+	if a, e := keyValueMap(MaskEnv(env, nil)), keyValueMap(env); !reflect.DeepEqual(a, e) {
+		t.Errorf("expected no masking without patterns, got %v", a)
+	}
+}
+
+func TestPlanChangeMasked(t *testing.T) {
+	patterns := []string{"FILE", "*_PASSWORD"}
+	cases := []struct {
+		Change   PlanChange
+		Expected PlanChange
+	}{
+		{
+			PlanChange{Field: "env DB_PASSWORD", Action: PlanActionChange, Before: "hunter2", After: "hunter3"},
+			PlanChange{Field: "env DB_PASSWORD", Action: PlanActionChange, Before: MaskedEnvValue, After: MaskedEnvValue},
+		},
+		{
+			PlanChange{Field: "env APP_ENV", Action: PlanActionAdd, After: "prod"},
+			PlanChange{Field: "env APP_ENV", Action: PlanActionAdd, After: "prod"},
+		},
+		{
+			PlanChange{Container: "app", Field: "env file", Action: PlanActionAdd, After: "arn:aws:s3:::configs/app.env"},
+			PlanChange{Container: "app", Field: "env file", Action: PlanActionAdd, After: "arn:aws:s3:::configs/app.env"},
+		},
+		{
+			PlanChange{Field: "secret DB_PASSWORD", Action: PlanActionAdd, After: "/outback/prod/api/DB_PASSWORD"},
+			PlanChange{Field: "secret DB_PASSWORD", Action: PlanActionAdd, After: "/outback/prod/api/DB_PASSWORD"},
+		},
+	}
+
+	for i, c := range cases {
+		if a, e := c.Change.Masked(patterns), c.Expected; a != e {
+			t.Errorf("%d, expected %v, got %v", i, e, a)
+		}
+	}
+}
+
+// mockedS3 is an in memory object store
+type mockedS3 struct {
+	s3iface.S3API
+	Objects map[string]*s3.PutObjectInput
+	Bodies  map[string]string
+}
+
+func (m *mockedS3) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	body, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	key := *in.Bucket + "/" + *in.Key
+	m.Objects[key] = in
+	m.Bodies[key] = string(body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestEnvFileKey(t *testing.T) {
+	key := EnvFileKey("dev", "api", "config/app.env", []byte("APP_ENV=dev\n"))
+
+	if !strings.HasPrefix(key, "outback/dev/api/app-") || !strings.HasSuffix(key, ".env") {
+		t.Errorf("unexpected key %v", key)
+	}
+
+	if a, e := EnvFileKey("dev", "api", "config/app.env", []byte("APP_ENV=dev\n")), key; a != e {
+		t.Errorf("expected the same contents to give the same key %v, got %v", e, a)
+	}
+
+	if a := EnvFileKey("dev", "api", "config/app.env", []byte("APP_ENV=prod\n")); a == key {
+		t.Errorf("expected changed contents to give a new key, got %v", a)
+	}
+
+	if a := EnvFileKey("dev", "api", "production", []byte("")); !strings.HasSuffix(a, ".env") {
+		t.Errorf("expected a .env extension, got %v", a)
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	env, err := ParseEnvFile(strings.NewReader("# comment\n\nAPP_ENV=dev\nQUOTED=\"a b\"\nURL=a=b\n"))
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []*ecs.KeyValuePair{
+		{Name: aws.String("APP_ENV"), Value: aws.String("dev")},
+		{Name: aws.String("QUOTED"), Value: aws.String("\"a b\"")},
+		{Name: aws.String("URL"), Value: aws.String("a=b")},
+	}
+
+	if a, e := env, expected; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	for _, contents := range []string{"export APP_ENV=dev\n", "APP_ENV = dev\n", "APP_ENV=dev\nAPP_ENV=prod\n", "APP_ENV\n"} {
+		if _, err := ParseEnvFile(strings.NewReader(contents)); err == nil {
+			t.Errorf("expected %q to be rejected", contents)
+		}
+	}
+}
+
+func TestOutbackUploadEnvFile(t *testing.T) {
+	mock := &mockedS3{Objects: map[string]*s3.PutObjectInput{}, Bodies: map[string]string{}}
+	outback := Outback{S3: mock}
+
+	arn, err := outback.UploadEnvFile("configs", "outback/dev/api/app-abc.env", []byte("APP_ENV=dev\n"))
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := arn, "arn:aws:s3:::configs/outback/dev/api/app-abc.env"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := mock.Bodies["configs/outback/dev/api/app-abc.env"], "APP_ENV=dev\n"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValue(mock.Objects["configs/outback/dev/api/app-abc.env"].ServerSideEncryption), s3.ServerSideEncryptionAes256; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackUploadEnvFileInvalid(t *testing.T) {
+	mock := &mockedS3{Objects: map[string]*s3.PutObjectInput{}, Bodies: map[string]string{}}
+	outback := Outback{S3: mock}
+
+	if _, err := outback.UploadEnvFile("configs", "outback/dev/api/app-abc.env", []byte("not a variable\n")); err == nil {
+		t.Errorf("expected an invalid file to be rejected")
+	}
+
+	if len(mock.Objects) != 0 {
+		t.Errorf("expected nothing to be uploaded, got %v", mock.Objects)
+	}
+}
+
+func TestOutbackAttachAndDetachContainerEnvFile(t *testing.T) {
+	outback := Outback{}
+	arn := "arn:aws:s3:::configs/outback/dev/api/app-abc.env"
+	current := ecs.TaskDefinition{
+		Family:            aws.String("api"),
+		TaskDefinitionArn: aws.String("task-definition/api:1"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{Name: aws.String("proxy"), Image: aws.String("nginx:latest")},
+			{Name: aws.String("app"), Image: aws.String("repo:abc")},
+		},
+	}
+
+	attached, err := outback.AttachContainerEnvFile(current, arn, "", "repo")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []*ecs.EnvironmentFile{{Type: aws.String(ecs.EnvironmentFileTypeS3), Value: aws.String(arn)}}
+
+	if a, e := attached.ContainerDefinitions[1].EnvironmentFiles, expected; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if len(current.ContainerDefinitions[1].EnvironmentFiles) != 0 {
+		t.Errorf("expected the current task definition to be unchanged")
+	}
+
+	again, _ := outback.AttachContainerEnvFile(attached, arn, "app", "")
+
+	if a, e := len(again.ContainerDefinitions[1].EnvironmentFiles), 1; a != e {
+		t.Errorf("expected attaching twice to keep %v file, got %v", e, a)
+	}
+
+	first := "arn:aws:s3:::configs/outback/dev/api/app-0123456789ab.env"
+	second := "arn:aws:s3:::configs/outback/dev/api/app-ba9876543210.env"
+	uploaded, _ := outback.AttachContainerEnvFile(attached, first, "app", "")
+	uploaded, _ = outback.AttachContainerEnvFile(uploaded, "arn:aws:s3:::configs/outback/dev/api/other-0123456789ab.env", "app", "")
+	uploaded, _ = outback.AttachContainerEnvFile(uploaded, second, "app", "")
+
+	expected = []*ecs.EnvironmentFile{
+		{Type: aws.String(ecs.EnvironmentFileTypeS3), Value: aws.String(arn)},
+		{Type: aws.String(ecs.EnvironmentFileTypeS3), Value: aws.String(second)},
+		{Type: aws.String(ecs.EnvironmentFileTypeS3), Value: aws.String("arn:aws:s3:::configs/outback/dev/api/other-0123456789ab.env")},
+	}
+
+	if a, e := uploaded.ContainerDefinitions[1].EnvironmentFiles, expected; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected a new upload to replace the earlier one %v, got %v", e, a)
+	}
+
+	changes := (&Plan{Current: &current, Desired: &attached, Register: true}).Changes()
+
+	if a, e := changes[1], (PlanChange{Container: "app", Field: "env file", Action: PlanActionAdd, After: arn}); a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	detached, ok, err := outback.DetachContainerEnvFile(attached, arn, "app", "")
+
+	if err != nil || !ok {
+		t.Fatalf("expected the file to be detached, got %v %v", ok, err)
+	}
+
+	if a := len(detached.ContainerDefinitions[1].EnvironmentFiles); a != 0 {
+		t.Errorf("expected no environment files, got %v", a)
+	}
+
+	if _, ok, _ := outback.DetachContainerEnvFile(detached, arn, "app", ""); ok {
+		t.Errorf("expected detaching a file that is not attached to report false")
+	}
+
+	if _, err := outback.AttachContainerEnvFile(current, arn, "worker", ""); err == nil {
+		t.Errorf("expected an unknown container to be an error")
+	}
+
+	for _, invalid := range []string{"s3://configs/app.env", "arn:aws:s3:::configs", "arn:aws:s3:::/app.env", "configs/app.env"} {
+		if _, err := outback.AttachContainerEnvFile(current, invalid, "app", ""); err == nil {
+			t.Errorf("expected %q to be rejected as an S3 object ARN", invalid)
+		}
+	}
+}
+
+// mockedScale is a service whose running count moves one task towards its desired count every
+// time it is described
+type mockedScale struct {
+	ecsiface.ECSAPI
+	Service *ecs.Service
+}
+
+func (m *mockedScale) UpdateService(in *ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error) {
+	m.Service.DesiredCount = in.DesiredCount
+	return &ecs.UpdateServiceOutput{Service: m.Service}, nil
+}
+
+func (m *mockedScale) DescribeServices(in *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	switch running, desired := *m.Service.RunningCount, *m.Service.DesiredCount; {
+	case running < desired:
+		m.Service.RunningCount = aws.Int64(running + 1)
+	case running > desired:
+		m.Service.RunningCount = aws.Int64(running - 1)
+	}
+
+	service := *m.Service
+	return &ecs.DescribeServicesOutput{Services: []*ecs.Service{&service}}, nil
+}
+
+// mockedAAS is an in memory set of scalable targets
+type mockedAAS struct {
+	applicationautoscalingiface.ApplicationAutoScalingAPI
+	Targets map[string]*applicationautoscaling.ScalableTarget
+}
+
+func (m *mockedAAS) DescribeScalableTargets(in *applicationautoscaling.DescribeScalableTargetsInput) (*applicationautoscaling.DescribeScalableTargetsOutput, error) {
+	targets := make([]*applicationautoscaling.ScalableTarget, 0)
+
+	for _, id := range in.ResourceIds {
+		if target, ok := m.Targets[*id]; ok {
+			targets = append(targets, target)
+		}
+	}
+
+	return &applicationautoscaling.DescribeScalableTargetsOutput{ScalableTargets: targets}, nil
+}
+
+func (m *mockedAAS) RegisterScalableTarget(in *applicationautoscaling.RegisterScalableTargetInput) (*applicationautoscaling.RegisterScalableTargetOutput, error) {
+	m.Targets[*in.ResourceId] = &applicationautoscaling.ScalableTarget{
+		ResourceId:  in.ResourceId,
+		MinCapacity: in.MinCapacity,
+		MaxCapacity: in.MaxCapacity,
+	}
+
+	return &applicationautoscaling.RegisterScalableTargetOutput{}, nil
+}
+
+func scaleFixture() (*ecs.Cluster, *ecs.Service) {
+	c := &ecs.Cluster{ClusterName: aws.String("dev"), ClusterArn: aws.String("arn:aws:ecs:us-east-1:111222333444:cluster/dev")}
+	s := &ecs.Service{
+		ServiceName:  aws.String("api"),
+		ServiceArn:   aws.String("arn:aws:ecs:us-east-1:111222333444:service/dev/api"),
+		DesiredCount: aws.Int64(1),
+		RunningCount: aws.Int64(1),
+		PendingCount: aws.Int64(0),
+	}
+
+	return c, s
+}
+
+func TestOutbackScaleService(t *testing.T) {
+	c, s := scaleFixture()
+	outback := Outback{ECS: &mockedScale{Service: s}}
+
+	scaled, err := outback.ScaleService(c, s, nil, 3)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := *scaled.DesiredCount, int64(3); a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	running := make([]int64, 0)
+	for service := range outback.AwaitServiceScaled(c, s, 3) {
+		running = append(running, *service.RunningCount)
+	}
+
+	if a, e := running, []int64{2, 3}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackScaleServiceOutsideScalableTarget(t *testing.T) {
+	c, s := scaleFixture()
+	outback := Outback{ECS: &mockedScale{Service: s}}
+	target := &applicationautoscaling.ScalableTarget{MinCapacity: aws.Int64(2), MaxCapacity: aws.Int64(4)}
+
+	if _, err := outback.ScaleService(c, s, target, 5); err == nil {
+		t.Errorf("expected a count above the max capacity to be an error")
+	}
+
+	if a, e := *s.DesiredCount, int64(1); a != e {
+		t.Errorf("expected the desired count to stay %v, got %v", e, a)
+	}
+}
+
+func TestOutbackUpdateScalableTarget(t *testing.T) {
+	c, s := scaleFixture()
+	mock := &mockedAAS{Targets: map[string]*applicationautoscaling.ScalableTarget{}}
+	outback := Outback{AAS: mock}
+
+	if err := outback.UpdateScalableTarget(c, s, nil, aws.Int64(2), nil); err == nil {
+		t.Errorf("expected a new target without max capacity to be an error")
+	}
+
+	if err := outback.UpdateScalableTarget(c, s, nil, aws.Int64(2), aws.Int64(6)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	target, err := outback.GetScalableTarget(c, s)
+
+	if err != nil || target == nil {
+		t.Fatalf("expected service/dev/api to be registered, got %v %v", target, err)
+	}
+
+	if err := outback.UpdateScalableTarget(c, s, target, nil, aws.Int64(10)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	updated := mock.Targets["service/dev/api"]
+
+	if a, e := []int64{*updated.MinCapacity, *updated.MaxCapacity}, []int64{2, 10}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if err := outback.UpdateScalableTarget(c, s, updated, aws.Int64(12), nil); err == nil {
+		t.Errorf("expected a min capacity above the max capacity to be an error")
+	}
+}
+
+// mockedRestart is a service whose old deployment drains the second time it is described
+// after a forced new deployment
+type mockedRestart struct {
+	ecsiface.ECSAPI
+	Service   *ecs.Service
+	Forced    bool
+	Described int
+}
+
+func (m *mockedRestart) UpdateService(in *ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error) {
+	m.Forced = aws.BoolValue(in.ForceNewDeployment)
+	m.Service.Deployments = []*ecs.Deployment{
+		{Id: aws.String("ecs-svc/2"), Status: aws.String("PRIMARY"), DesiredCount: aws.Int64(2), RunningCount: aws.Int64(0)},
+		{Id: aws.String("ecs-svc/1"), Status: aws.String("ACTIVE"), DesiredCount: aws.Int64(2), RunningCount: aws.Int64(2)},
+	}
+
+	service := *m.Service
+	return &ecs.UpdateServiceOutput{Service: &service}, nil
+}
+
+func (m *mockedRestart) DescribeServices(in *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	m.Described++
+
+	if m.Described > 1 {
+		m.Service.Deployments = []*ecs.Deployment{
+			{Id: aws.String("ecs-svc/2"), Status: aws.String("PRIMARY"), DesiredCount: aws.Int64(2), RunningCount: aws.Int64(2)},
+		}
+	}
+
+	service := *m.Service
+	return &ecs.DescribeServicesOutput{Services: []*ecs.Service{&service}}, nil
+}
+
+func TestOutbackRestartAll(t *testing.T) {
+	c, s := scaleFixture()
+	s.Deployments = []*ecs.Deployment{
+		{Id: aws.String("ecs-svc/1"), Status: aws.String("PRIMARY"), DesiredCount: aws.Int64(2), RunningCount: aws.Int64(2)},
+	}
+	mock := &mockedRestart{Service: s}
+	outback := Outback{ECS: mock}
+
+	deployment := &Deployment{DeployDetails: []*DeployDetail{{Cluster: c, Service: s}}}
+
+	for err := range outback.RestartAll(deployment) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !mock.Forced {
+		t.Errorf("expected a new deployment to be forced")
+	}
+
+	detail := deployment.DeployDetails[0]
+
+	if a, e := aws.StringValue(PrimaryDeployment(detail.Service).Id), "ecs-svc/2"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if outback.IsServiceRestarted(detail) {
+		t.Errorf("expected the service not to be restarted while the old deployment runs")
+	}
+
+	select {
+	case done := <-outback.AwaitServicesRestarted(deployment):
+		if done != detail {
+			t.Errorf("expected %v, got %v", detail, done)
+		}
+	case <-time.After(time.Second * 10):
+		t.Errorf("timed out waiting for the restart")
+	}
+}
+
+type mockedServiceInfo struct {
+	ecsiface.ECSAPI
+	TaskDefinition *ecs.TaskDefinition
+	Tasks          map[string][]*ecs.Task
+	PageSize       int
+}
+
+func (m mockedServiceInfo) DescribeTaskDefinition(in *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: m.TaskDefinition}, nil
+}
+
+func (m mockedServiceInfo) ListTasks(in *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	arns := make([]*string, 0)
+	for _, task := range m.Tasks[*in.DesiredStatus] {
+		arns = append(arns, task.TaskArn)
+	}
+
+	if m.PageSize == 0 {
+		return &ecs.ListTasksOutput{TaskArns: arns}, nil
+	}
+
+	start, _ := strconv.Atoi(aws.StringValue(in.NextToken))
+	end := start + m.PageSize
+
+	if end >= len(arns) {
+		return &ecs.ListTasksOutput{TaskArns: arns[start:]}, nil
+	}
+
+	return &ecs.ListTasksOutput{TaskArns: arns[start:end], NextToken: aws.String(strconv.Itoa(end))}, nil
+}
+
+func (m mockedServiceInfo) DescribeTasks(in *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	if len(in.Tasks) == 0 {
+		return nil, errors.New("Tasks should have at least 1 item")
+	}
+
+	if len(in.Tasks) > 100 {
+		return nil, errors.New("Tasks should have at most 100 items")
+	}
+
+	tasks := make([]*ecs.Task, 0)
+	for _, status := range m.Tasks {
+		for _, task := range status {
+			for _, arn := range in.Tasks {
+				if *arn == *task.TaskArn {
+					tasks = append(tasks, task)
+				}
+			}
+		}
+	}
+
+	return &ecs.DescribeTasksOutput{Tasks: tasks}, nil
+}
+
+func TestOutbackGetServiceDetail(t *testing.T) {
+	c, s := scaleFixture()
+	started := time.Date(2021, 10, 4, 12, 0, 0, 0, time.UTC)
+	s.TaskDefinition = aws.String("arn:aws:ecs:us-east-1:111222333444:task-definition/api:40")
+	s.Deployments = []*ecs.Deployment{
+		{Id: aws.String("ecs-svc/2"), Status: aws.String("PRIMARY"), RolloutState: aws.String("IN_PROGRESS"), TaskDefinition: s.TaskDefinition, DesiredCount: aws.Int64(1), RunningCount: aws.Int64(0), PendingCount: aws.Int64(1)},
+		{Id: aws.String("ecs-svc/1"), Status: aws.String("ACTIVE"), RolloutState: aws.String("COMPLETED"), DesiredCount: aws.Int64(1), RunningCount: aws.Int64(1)},
+	}
+	s.LoadBalancers = []*ecs.LoadBalancer{{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:111222333444:targetgroup/api/abc"), ContainerName: aws.String("app"), ContainerPort: aws.Int64(8080)}}
+	s.Events = []*ecs.ServiceEvent{
+		{CreatedAt: aws.Time(started.Add(time.Minute)), Message: aws.String("(service api) has started 1 tasks")},
+		{CreatedAt: aws.Time(started), Message: aws.String("(service api) has reached a steady state.")},
+	}
+
+	taskDef := deployedRevision("api", 40, "aaaaaaa")
+	taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions, &ecs.ContainerDefinition{Name: aws.String("proxy"), Image: aws.String("nginx:latest")})
+
+	outback := Outback{ECS: mockedServiceInfo{
+		TaskDefinition: taskDef,
+		Tasks: map[string][]*ecs.Task{
+			ecs.DesiredStatusRunning: {{TaskArn: aws.String("task/dev/1"), LastStatus: aws.String("RUNNING"), HealthStatus: aws.String("HEALTHY"), StartedAt: aws.Time(started)}},
+			ecs.DesiredStatusStopped: {{TaskArn: aws.String("task/dev/0"), LastStatus: aws.String("STOPPED"), StoppedReason: aws.String("Essential container in task exited")}},
+		},
+	}}
+
+	detail, err := outback.GetServiceDetail(c, s, 1)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := detail.TaskDefinition.Revision, int64(40); a != e || !detail.TaskDefinition.Current {
+		t.Errorf("expected current revision %v, got %v", e, a)
+	}
+
+	if a, e := detail.Containers, []*ContainerDetail{{Name: "app", Image: "repo:aaaaaaa"}, {Name: "proxy", Image: "nginx:latest"}}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected every container %v, got %v", e, a)
+	}
+
+	if a, e := detail.Deployments[0], (&DeploymentDetail{ID: "ecs-svc/2", Status: "PRIMARY", RolloutState: "IN_PROGRESS", TaskDefinitionArn: *s.TaskDefinition, DesiredCount: 1, PendingCount: 1}); !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := detail.LoadBalancers[0].ContainerPort, int64(8080); a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	expectedTasks := []*TaskDetail{
+		{TaskArn: "task/dev/1", LastStatus: "RUNNING", HealthStatus: "HEALTHY", StartedAt: "2021-10-04T12:00:00Z"},
+		{TaskArn: "task/dev/0", LastStatus: "STOPPED", StoppedReason: "Essential container in task exited"},
+	}
+
+	if a, e := detail.Tasks, expectedTasks; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := detail.Events, []*EventDetail{{CreatedAt: "2021-10-04T12:01:00Z", Message: "(service api) has started 1 tasks"}}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackGetServiceDetailPages(t *testing.T) {
+	c, s := scaleFixture()
+	s.TaskDefinition = aws.String("arn:aws:ecs:us-east-1:111222333444:task-definition/api:40")
+	running := make([]*ecs.Task, 0)
+
+	for i := 0; i < 250; i++ {
+		running = append(running, &ecs.Task{TaskArn: aws.String(fmt.Sprintf("task/dev/%d", i)), LastStatus: aws.String("RUNNING")})
+	}
+
+	outback := Outback{ECS: mockedServiceInfo{
+		TaskDefinition: deployedRevision("api", 40, "aaaaaaa"),
+		Tasks:          map[string][]*ecs.Task{ecs.DesiredStatusRunning: running},
+		PageSize:       100,
+	}}
+
+	detail, err := outback.GetServiceDetail(c, s, 1)
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := len(detail.Tasks), 250; a != e {
+		t.Fatalf("expected every page to be described %v, got %v", e, a)
+	}
+
+	if a, e := detail.Tasks[249].TaskArn, "task/dev/249"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+// mockedRunTaskInput records the run task request
+type mockedRunTaskInput struct {
+	ecsiface.ECSAPI
+	Input *ecs.RunTaskInput
+}
+
+func (m *mockedRunTaskInput) RunTask(in *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	m.Input = in
+	return &ecs.RunTaskOutput{Tasks: []*ecs.Task{{TaskArn: aws.String("task/dev/1")}}}, nil
+}
+
+func fargateFixture() (*ecs.Service, *ecs.TaskDefinition) {
+	s := &ecs.Service{
+		ServiceName: aws.String("api"),
+		CapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(1)},
+		},
+		PlatformVersion: aws.String("1.4.0"),
+		NetworkConfiguration: &ecs.NetworkConfiguration{AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+			Subnets:        aws.StringSlice([]string{"subnet-a", "subnet-b"}),
+			SecurityGroups: aws.StringSlice([]string{"sg-api"}),
+			AssignPublicIp: aws.String(ecs.AssignPublicIpDisabled),
+		}},
+	}
+
+	t := &ecs.TaskDefinition{
+		Family:               aws.String("api"),
+		TaskDefinitionArn:    aws.String("task-definition/api:1"),
+		NetworkMode:          aws.String(ecs.NetworkModeAwsvpc),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{Name: aws.String("app")}},
+	}
+
+	return s, t
+}
+
+func TestOutbackRunTaskWithServiceLaunch(t *testing.T) {
+	s, taskDef := fargateFixture()
+	mock := &mockedRunTaskInput{}
+	outback := Outback{ECS: mock}
+
+	_, err := outback.RunTask(&ecs.Cluster{ClusterName: aws.String("dev")}, taskDef, TaskOverrides{Command: "rake db:migrate"}, ServiceTaskLaunch(s))
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if mock.Input.LaunchType != nil {
+		t.Errorf("expected no launch type with a capacity provider strategy, got %v", *mock.Input.LaunchType)
+	}
+
+	if a, e := mock.Input.CapacityProviderStrategy, s.CapacityProviderStrategy; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValue(mock.Input.PlatformVersion), "1.4.0"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := mock.Input.NetworkConfiguration, s.NetworkConfiguration; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackRunTaskWithLaunchOverrides(t *testing.T) {
+	s, taskDef := fargateFixture()
+	mock := &mockedRunTaskInput{}
+	outback := Outback{ECS: mock}
+
+	launch := ServiceTaskLaunch(s)
+	launch.SetLaunchType("fargate")
+	launch.Subnets = []string{"subnet-private"}
+	launch.AssignPublicIP = "enabled"
+
+	if _, err := outback.RunTask(&ecs.Cluster{ClusterName: aws.String("dev")}, taskDef, TaskOverrides{Command: "rake db:migrate"}, launch); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := aws.StringValue(mock.Input.LaunchType), ecs.LaunchTypeFargate; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if mock.Input.CapacityProviderStrategy != nil {
+		t.Errorf("expected the capacity provider strategy to be replaced by the launch type")
+	}
+
+	vpc := mock.Input.NetworkConfiguration.AwsvpcConfiguration
+
+	if a, e := aws.StringValueSlice(vpc.Subnets), []string{"subnet-private"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValueSlice(vpc.SecurityGroups), []string{"sg-api"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValue(vpc.AssignPublicIp), ecs.AssignPublicIpEnabled; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackRunTaskAwsvpcWithoutSubnets(t *testing.T) {
+	_, taskDef := fargateFixture()
+	mock := &mockedRunTaskInput{}
+	outback := Outback{ECS: mock}
+
+	if _, err := outback.RunTask(&ecs.Cluster{ClusterName: aws.String("dev")}, taskDef, TaskOverrides{Command: "ls"}, &TaskLaunch{LaunchType: ecs.LaunchTypeFargate}); err == nil {
+		t.Errorf("expected an awsvpc task without subnets to be an error")
+	}
+
+	if mock.Input != nil {
+		t.Errorf("expected no task to be run")
+	}
+}
+
+func TestTaskLaunchSetCapacityProviders(t *testing.T) {
+	launch := &TaskLaunch{LaunchType: ecs.LaunchTypeFargate}
+
+	if err := launch.SetCapacityProviders([]string{"FARGATE:1:2", "FARGATE_SPOT:3"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []*ecs.CapacityProviderStrategyItem{
+		{CapacityProvider: aws.String("FARGATE"), Weight: aws.Int64(1), Base: aws.Int64(2)},
+		{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(3)},
+	}
+
+	if a, e := launch.CapacityProviderStrategy, expected; !reflect.DeepEqual(a, e) || launch.LaunchType != "" {
+		t.Errorf("expected %v without a launch type, got %v %v", e, a, launch.LaunchType)
+	}
+
+	for _, invalid := range []string{"", "FARGATE:x", "FARGATE:1:2:3", "FARGATE:-1"} {
+		if err := launch.SetCapacityProviders([]string{invalid}); err == nil {
+			t.Errorf("expected %q to be an error", invalid)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		Command  string
+		Expected []string
+	}{
+		{`php artisan migrate`, []string{"php", "artisan", "migrate"}},
+		{`  php   artisan  `, []string{"php", "artisan"}},
+		{`php artisan tinker --execute="echo 1"`, []string{"php", "artisan", "tinker", "--execute=echo 1"}},
+		{`sh -c 'echo "$HOME"'`, []string{"sh", "-c", `echo "$HOME"`}},
+		{`echo "a \"quoted\" \$word" \\n`, []string{"echo", `a "quoted" $word`, `\n`}},
+		{`echo one\ argument ""`, []string{"echo", "one argument", ""}},
+		{`echo "\d"`, []string{"echo", `\d`}},
+		{``, []string{}},
+	}
+
+	for i, c := range cases {
+		args, err := SplitCommand(c.Command)
+
+		if err != nil {
+			t.Fatalf("%d, unexpected error %v", i, err)
+		}
+
+		if a, e := args, c.Expected; !reflect.DeepEqual(a, e) {
+			t.Errorf("%d, expected %q, got %q", i, e, a)
+		}
+	}
+
+	for _, invalid := range []string{`echo "unterminated`, `echo 'unterminated`, `echo \`} {
+		if _, err := SplitCommand(invalid); err == nil {
+			t.Errorf("expected %q to be an error", invalid)
+		}
+	}
+}
+
+func TestOutbackRunTaskOverrides(t *testing.T) {
+	mock := &mockedRunTaskInput{}
+	outback := Outback{ECS: mock}
+	taskDef := &ecs.TaskDefinition{
+		TaskDefinitionArn: aws.String("task-definition/api:1"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{Name: aws.String("app")},
+			{Name: aws.String("worker")},
+		},
+	}
+
+	overrides := TaskOverrides{
+		Container: "worker",
+		Command:   `php artisan tinker --execute="echo 1"`,
+		Env:       []*ecs.KeyValuePair{{Name: aws.String("LOG_LEVEL"), Value: aws.String("debug")}},
+		Cpu:       "1024",
+		Memory:    "2048",
+	}
+
+	if _, err := outback.RunTask(&ecs.Cluster{ClusterName: aws.String("dev")}, taskDef, overrides, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := &ecs.TaskOverride{
+		ContainerOverrides: []*ecs.ContainerOverride{{
+			Name:        aws.String("worker"),
+			Command:     aws.StringSlice([]string{"php", "artisan", "tinker", "--execute=echo 1"}),
+			Environment: overrides.Env,
+		}},
+		Cpu:    aws.String("1024"),
+		Memory: aws.String("2048"),
+	}
+
+	if a, e := mock.Input.Overrides, expected; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if _, err := outback.RunTask(&ecs.Cluster{ClusterName: aws.String("dev")}, taskDef, TaskOverrides{Container: "proxy"}, nil); err == nil {
+		t.Errorf("expected an unknown container to be an error")
+	}
+}
+
+type mockedRunTaskFailure struct {
+	ecsiface.ECSAPI
+}
+
+func (m mockedRunTaskFailure) RunTask(in *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	return &ecs.RunTaskOutput{
+		Tasks:    []*ecs.Task{},
+		Failures: []*ecs.Failure{{Arn: aws.String("arn:container-instance/dev/1"), Reason: aws.String("RESOURCE:MEMORY")}},
+	}, nil
+}
+
+func TestOutbackRunTaskFailure(t *testing.T) {
+	outback := Outback{ECS: mockedRunTaskFailure{}}
+	taskDef := deployedRevision("api", 40, "aaaaaaa")
+
+	_, err := outback.RunTask(&ecs.Cluster{ClusterName: aws.String("dev")}, taskDef, TaskOverrides{}, nil)
+
+	if err == nil {
+		t.Fatalf("expected a task that could not be placed to be an error")
+	}
+
+	if !strings.Contains(err.Error(), "RESOURCE:MEMORY") {
+		t.Errorf("expected the failure reason in the error, got %v", err)
+	}
+}
+
+func TestOutbackRunTaskDefinitionEntrypoint(t *testing.T) {
+	mock := &mockedRegisterTaskDefinitionWithTags{}
+	outback := Outback{ECS: mock}
+	taskDef := deployedRevision("api", 40, "aaaaaaa")
+
+	unchanged, err := outback.RunTaskDefinition(taskDef, TaskOverrides{Command: "ls"})
+
+	if err != nil || unchanged != taskDef || mock.Input != nil {
+		t.Errorf("expected the task definition to be run as is without an entrypoint")
+	}
+
+	if _, err := outback.RunTaskDefinition(taskDef, TaskOverrides{Entrypoint: `/bin/sh -c`}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := aws.StringValue(mock.Input.Family), "api-run"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValueSlice(mock.Input.ContainerDefinitions[0].EntryPoint), []string{"/bin/sh", "-c"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if taskDef.ContainerDefinitions[0].EntryPoint != nil || aws.StringValue(taskDef.Family) != "api" {
+		t.Errorf("expected the service's task definition to be unchanged")
+	}
+}
+
+func TestOutbackReleaseRunTaskDefinition(t *testing.T) {
+	mock := &mockedDeployStages{}
+	outback := Outback{ECS: mock}
+	taskDef := deployedRevision("api", 40, "aaaaaaa")
+
+	for _, arn := range []string{aws.StringValue(taskDef.TaskDefinitionArn), "arn:aws:ecs:us-east-1:111222333444:task-definition/api-runner:3", "arn:aws:ecs:us-east-1:111222333444:task-definition/api-run:7"} {
+		if err := outback.ReleaseRunTaskDefinition(taskDef, arn); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	if a, e := mock.Calls, []string{"deregister arn:aws:ecs:us-east-1:111222333444:task-definition/api-run:7"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected only the run revision to be deregistered %v, got %v", e, a)
+	}
+}
+
+func TestTaskFailure(t *testing.T) {
+	taskDef := &ecs.TaskDefinition{ContainerDefinitions: []*ecs.ContainerDefinition{
+		{Name: aws.String("app")},
+		{Name: aws.String("log-router"), Essential: aws.Bool(false)},
+	}}
+
+	cases := []struct {
+		Containers []*ecs.Container
+		Failed     bool
+	}{
+		{[]*ecs.Container{{Name: aws.String("app"), ExitCode: aws.Int64(0)}, {Name: aws.String("log-router"), ExitCode: aws.Int64(137)}}, false},
+		{[]*ecs.Container{{Name: aws.String("app"), ExitCode: aws.Int64(1)}, {Name: aws.String("log-router"), ExitCode: aws.Int64(0)}}, true},
+		{[]*ecs.Container{{Name: aws.String("app"), Reason: aws.String("CannotPullContainerError")}}, true},
+	}
+
+	for i, c := range cases {
+		task := &ecs.Task{Containers: c.Containers, StoppedReason: aws.String("Essential container in task exited")}
+
+		if a, e := TaskFailure(task, taskDef) != nil, c.Failed; a != e {
+			t.Errorf("%d, expected failed %v, got %v", i, e, a)
+		}
+	}
+}
+
+type mockedDeployStages struct {
+	ecsiface.ECSAPI
+	Calls []string
+}
+
+func (m *mockedDeployStages) DescribeTaskDefinition(in *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{
+		TaskDefinitionArn: aws.String("api:1"),
+		Family:            aws.String("api"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:  aws.String("app"),
+			Image: aws.String("repo:abc"),
+		}},
+	}}, nil
+}
+
+func (m *mockedDeployStages) RegisterTaskDefinition(in *ecs.RegisterTaskDefinitionInput) (*ecs.RegisterTaskDefinitionOutput, error) {
+	m.Calls = append(m.Calls, "register "+aws.StringValue(in.ContainerDefinitions[0].Image))
+	return &ecs.RegisterTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{TaskDefinitionArn: aws.String("api:2")}}, nil
+}
+
+func (m *mockedDeployStages) UpdateService(in *ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error) {
+	m.Calls = append(m.Calls, "update "+aws.StringValue(in.TaskDefinition))
+	return &ecs.UpdateServiceOutput{}, nil
+}
+
+func (m *mockedDeployStages) DeregisterTaskDefinition(in *ecs.DeregisterTaskDefinitionInput) (*ecs.DeregisterTaskDefinitionOutput, error) {
+	m.Calls = append(m.Calls, "deregister "+aws.StringValue(in.TaskDefinition))
+	return &ecs.DeregisterTaskDefinitionOutput{}, nil
+}
+
+func deployStagesFixture(outback *Outback) (*Deployment, *DeployDetail) {
+	previous := &ecs.TaskDefinition{TaskDefinitionArn: aws.String("api:1")}
+	detail := outback.NewDeployDetail()
+	detail.SetCluster(&ecs.Cluster{})
+	detail.SetService(&ecs.Service{TaskDefinition: aws.String("api:1")})
+	detail.SetTaskDefinition(previous)
+	detail.SetPreviousTaskDefinition(previous)
+
+	deployment := &Deployment{DeployDetails: []*DeployDetail{detail}}
+	deployment.SetRepo("repo")
+	deployment.SetCommitHash("def")
+
+	return deployment, detail
+}
+
+func TestOutbackRegisterAllAndUpdateAll(t *testing.T) {
+	mock := &mockedDeployStages{}
+	outback := Outback{ECS: mock, ECR: mockedECRClient{}}
+	deployment, detail := deployStagesFixture(&outback)
+
+	for err := range outback.RegisterAll(deployment) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := mock.Calls, []string{"register repo:def"}; !reflect.DeepEqual(a, e) {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+
+	if !detail.Changed() {
+		t.Errorf("expected the detail to hold the registered task definition")
+	}
+
+	for err := range outback.UpdateAll(deployment) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := mock.Calls, []string{"register repo:def", "update api:2"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackDeregisterAll(t *testing.T) {
+	mock := &mockedDeployStages{}
+	outback := Outback{ECS: mock, ECR: mockedECRClient{}}
+	deployment, detail := deployStagesFixture(&outback)
+
+	for err := range outback.RegisterAll(deployment) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for err := range outback.DeregisterAll(deployment) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := mock.Calls, []string{"register repo:def", "deregister api:2"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if detail.Changed() {
+		t.Errorf("expected the detail to point back to the running task definition")
+	}
+
+	// nothing is left to deregister
+	for err := range outback.DeregisterAll(deployment) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(mock.Calls) != 2 {
+		t.Errorf("expected no further calls, got %v", mock.Calls)
+	}
+}
+
+type mockedEventBridge struct {
+	eventbridgeiface.EventBridgeAPI
+	Rules    []*eventbridge.Rule
+	Targets  map[string][]*eventbridge.Target
+	Put      []*eventbridge.Target
+	PutRules []*eventbridge.PutRuleInput
+}
+
+func (m *mockedEventBridge) PutRule(in *eventbridge.PutRuleInput) (*eventbridge.PutRuleOutput, error) {
+	m.PutRules = append(m.PutRules, in)
+	return &eventbridge.PutRuleOutput{RuleArn: aws.String("arn:rule/" + aws.StringValue(in.Name))}, nil
+}
+
+func (m *mockedEventBridge) DescribeRule(in *eventbridge.DescribeRuleInput) (*eventbridge.DescribeRuleOutput, error) {
+	for _, rule := range m.Rules {
+		if aws.StringValue(rule.Name) == aws.StringValue(in.Name) {
+			return &eventbridge.DescribeRuleOutput{Name: rule.Name, ScheduleExpression: rule.ScheduleExpression, State: rule.State}, nil
+		}
+	}
+
+	return nil, awserr.New(eventbridge.ErrCodeResourceNotFoundException, "rule not found", nil)
+}
+
+func (m *mockedEventBridge) ListRules(in *eventbridge.ListRulesInput) (*eventbridge.ListRulesOutput, error) {
+	return &eventbridge.ListRulesOutput{Rules: m.Rules}, nil
+}
+
+func (m *mockedEventBridge) ListTargetsByRule(in *eventbridge.ListTargetsByRuleInput) (*eventbridge.ListTargetsByRuleOutput, error) {
+	return &eventbridge.ListTargetsByRuleOutput{Targets: m.Targets[aws.StringValue(in.Rule)]}, nil
+}
+
+func (m *mockedEventBridge) PutTargets(in *eventbridge.PutTargetsInput) (*eventbridge.PutTargetsOutput, error) {
+	m.Put = append(m.Put, in.Targets...)
+	return &eventbridge.PutTargetsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+func scheduleFixture() *mockedEventBridge {
+	target := func(cluster string) []*eventbridge.Target {
+		return []*eventbridge.Target{{
+			Id:            aws.String(ScheduleTargetID),
+			Arn:           aws.String(cluster),
+			EcsParameters: &eventbridge.EcsParameters{TaskDefinitionArn: aws.String("task-definition/api:1")},
+			Input:         aws.String(`{"containerOverrides":[{"name":"app"}]}`),
+		}}
+	}
+
+	return &mockedEventBridge{
+		Rules: []*eventbridge.Rule{
+			{Name: aws.String("outback-dev-report"), ScheduleExpression: aws.String("rate(1 hour)"), State: aws.String("ENABLED")},
+			{Name: aws.String("outback-dev-eu-report"), ScheduleExpression: aws.String("rate(1 day)"), State: aws.String("ENABLED")},
+		},
+		Targets: map[string][]*eventbridge.Target{
+			"outback-dev-report":    target("arn:cluster/dev"),
+			"outback-dev-eu-report": target("arn:cluster/dev-eu"),
+		},
+	}
+}
+
+func TestScheduleRuleName(t *testing.T) {
+	if a, e := ScheduleRuleName("dev", "db:backup nightly"), "outback-dev-db-backup-nightly"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a := ScheduleRuleName("dev", strings.Repeat("a", 100)); len(a) != 64 {
+		t.Errorf("expected the rule name to be cut to 64 characters, got %d", len(a))
+	}
+}
+
+func TestOutbackScheduleTarget(t *testing.T) {
+	s, taskDef := fargateFixture()
+	outback := Outback{}
+	overrides := TaskOverrides{
+		Command: `rake "report:send[daily]"`,
+		Env:     []*ecs.KeyValuePair{{Name: aws.String("LEVEL"), Value: aws.String("debug")}},
+		Memory:  "1024",
+	}
+
+	target, err := outback.ScheduleTarget(&ecs.Cluster{ClusterArn: aws.String("arn:cluster/dev")}, taskDef, overrides, ServiceTaskLaunch(s), "arn:role/events")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := `{"containerOverrides":[{"name":"app","command":["rake","report:send[daily]"],"environment":[{"name":"LEVEL","value":"debug"}]}],"memory":"1024"}`
+	if a := aws.StringValue(target.Input); a != expected {
+		t.Errorf("expected %v, got %v", expected, a)
+	}
+
+	params := target.EcsParameters
+	if a, e := aws.StringValue(params.TaskDefinitionArn), "task-definition/api:1"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValue(params.CapacityProviderStrategy[0].CapacityProvider), "FARGATE_SPOT"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValueSlice(params.NetworkConfiguration.AwsvpcConfiguration.Subnets), []string{"subnet-a", "subnet-b"}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValue(target.Arn), "arn:cluster/dev"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackGetScheduleNotFound(t *testing.T) {
+	outback := Outback{EB: scheduleFixture()}
+
+	s, err := outback.GetSchedule("outback-dev-missing")
+
+	if err != nil || s != nil {
+		t.Errorf("expected no schedule and no error, got %v, %v", s, err)
+	}
+}
+
+func TestOutbackListSchedules(t *testing.T) {
+	outback := Outback{EB: scheduleFixture()}
+
+	schedules, err := outback.ListSchedules(&ecs.Cluster{ClusterArn: aws.String("arn:cluster/dev")})
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(schedules) != 1 || schedules[0].Rule != "outback-dev-report" {
+		t.Fatalf("expected only the schedule of the cluster, got %v", schedules)
+	}
+
+	if a, e := schedules[0].TaskDefinition(), "task-definition/api:1"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestOutbackPutScheduleState(t *testing.T) {
+	mock := scheduleFixture()
+	outback := Outback{EB: mock}
+	target := mock.Targets["outback-dev-report"][0]
+
+	if err := outback.PutSchedule("outback-dev-report", "rate(2 hours)", eventbridge.RuleStateDisabled, "report", target); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := aws.StringValue(mock.PutRules[0].State), eventbridge.RuleStateDisabled; a != e {
+		t.Errorf("expected the state of the rule to be kept %v, got %v", e, a)
+	}
+
+	if err := outback.PutSchedule("outback-dev-new", "rate(1 hour)", "", "new", target); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if mock.PutRules[1].State != nil {
+		t.Errorf("expected a new rule to be put without a state, got %v", aws.StringValue(mock.PutRules[1].State))
+	}
+
+	if a, e := len(mock.Put), 2; a != e {
+		t.Errorf("expected a target to be put for every rule %v, got %v", e, a)
+	}
+}
+
+func TestOutbackRepointSchedule(t *testing.T) {
+	mock := scheduleFixture()
+	outback := Outback{EB: mock}
+
+	s, err := outback.GetSchedule("outback-dev-report")
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = outback.RepointSchedule(s, &ecs.TaskDefinition{TaskDefinitionArn: aws.String("task-definition/api:2")})
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a, e := aws.StringValue(mock.Put[0].EcsParameters.TaskDefinitionArn), "task-definition/api:2"; a != e {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if a, e := aws.StringValue(mock.Put[0].Input), `{"containerOverrides":[{"name":"app"}]}`; a != e {
+		t.Errorf("expected the overrides to be kept, got %v", a)
+	}
+
+	if a, e := aws.StringValue(mock.Targets["outback-dev-report"][0].EcsParameters.TaskDefinitionArn), "task-definition/api:1"; a != e {
+		t.Errorf("expected the listed target to be unchanged, got %v", a)
+	}
+}
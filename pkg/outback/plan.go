@@ -0,0 +1,332 @@
+package outback
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+const (
+	PlanActionAdd    = "add"
+	PlanActionRemove = "remove"
+	PlanActionChange = "change"
+)
+
+// Plan describes how a service will change without changing anything. A plan is computed
+// from the current state of a service and can be printed or applied with ApplyPlan.
+type Plan struct {
+	Cluster *ecs.Cluster
+	Service *ecs.Service
+	Current *ecs.TaskDefinition
+	Desired *ecs.TaskDefinition
+	// Register is true when Desired is a new revision that has to be registered before
+	// the service can use it, false when it is an existing revision
+	Register bool
+}
+
+// PlanChange is a single difference between the current and desired task definition
+type PlanChange struct {
+	Container string
+	Field     string
+	Action    string
+	Before    string
+	After     string
+}
+
+// Masked returns a copy of a change with the values of a sensitive environment variable
+// replaced by MaskedEnvValue, the same way MaskEnv masks an environment. Environment files are
+// listed by their ARN and secrets by the ARN they are read from, so neither is masked.
+func (c PlanChange) Masked(patterns []string) PlanChange {
+	if c.Field == "env file" || !strings.HasPrefix(c.Field, "env ") {
+		return c
+	}
+
+	if SensitiveEnv(strings.TrimPrefix(c.Field, "env "), patterns) {
+		c.Before = MaskedEnvValue
+		c.After = MaskedEnvValue
+	}
+
+	return c
+}
+
+// NewPlan creates a plan moving a service from its current to the desired task definition
+func (u *Outback) NewPlan(c *ecs.Cluster, s *ecs.Service, current *ecs.TaskDefinition, desired *ecs.TaskDefinition, register bool) *Plan {
+	return &Plan{
+		Cluster:  c,
+		Service:  s,
+		Current:  current,
+		Desired:  desired,
+		Register: register,
+	}
+}
+
+// PlanImage computes the task definition RegisterTaskDefinitionWithImage would register
+func (u *Outback) PlanImage(c *ecs.Cluster, s *ecs.Service, repo string, tag string) (*Plan, error) {
+	t, err := u.GetTaskDefinition(c, s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return u.NewPlan(c, s, t, u.TaskDefinitionWithImage(t, repo, tag), true), nil
+}
+
+// PlanRollback resolves the revision RollbackTarget would point the service to
+func (u *Outback) PlanRollback(c *ecs.Cluster, s *ecs.Service, t *ecs.TaskDefinition, n int) (*Plan, error) {
+	target, err := u.RollbackTarget(s, t, n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return u.NewPlan(c, s, t, target, false), nil
+}
+
+// PlanRollbackToCommit resolves the newest revision of the service's task definition family
+// that was deployed with the given git commit
+func (u *Outback) PlanRollbackToCommit(c *ecs.Cluster, s *ecs.Service, t *ecs.TaskDefinition, commit string) (*Plan, error) {
+	target, err := u.FindTaskDefinitionByCommit(aws.StringValue(t.Family), commit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return u.NewPlan(c, s, t, target, false), nil
+}
+
+// ApplyPlan registers the desired task definition if needed and updates the service to use it
+func (u *Outback) ApplyPlan(p *Plan) (*ecs.TaskDefinition, error) {
+	t := p.Desired
+
+	if p.Register {
+		registered, err := u.RegisterTaskDefinitionWithEnvVars(p.Desired)
+
+		if err != nil {
+			return nil, err
+		}
+
+		t = registered
+	}
+
+	_, err := u.UpdateService(p.Cluster, p.Service, t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Changes lists the revision, image and environment differences between the current and
+// desired task definition. Containers are matched by name.
+func (p *Plan) Changes() []PlanChange {
+	changes := make([]PlanChange, 0)
+
+	before := familyRevision(p.Current)
+	after := familyRevision(p.Desired)
+	if p.Register {
+		after = fmt.Sprintf("%s:(new)", aws.StringValue(p.Current.Family))
+	}
+
+	if before != after {
+		changes = append(changes, PlanChange{Field: "revision", Action: PlanActionChange, Before: before, After: after})
+	}
+
+	current := containersByName(p.Current)
+	desired := containersByName(p.Desired)
+
+	for _, name := range sortedContainerNames(current, desired) {
+		cur, inCurrent := current[name]
+		des, inDesired := desired[name]
+
+		switch {
+		case !inDesired:
+			changes = append(changes, PlanChange{Container: name, Field: "container", Action: PlanActionRemove, Before: aws.StringValue(cur.Image)})
+			continue
+		case !inCurrent:
+			changes = append(changes, PlanChange{Container: name, Field: "container", Action: PlanActionAdd, After: aws.StringValue(des.Image)})
+			continue
+		}
+
+		if a, b := aws.StringValue(cur.Image), aws.StringValue(des.Image); a != b {
+			changes = append(changes, PlanChange{Container: name, Field: "image", Action: PlanActionChange, Before: a, After: b})
+		}
+
+		changes = append(changes, envChanges(name, "env", cur.Environment, des.Environment)...)
+		changes = append(changes, envChanges(name, "secret", secretKeyValues(cur.Secrets), secretKeyValues(des.Secrets))...)
+		changes = append(changes, envFileChanges(name, cur.EnvironmentFiles, des.EnvironmentFiles)...)
+	}
+
+	return changes
+}
+
+// EnvDiff compares the environment and secrets of two containers, leaving out the deploy
+// markers which differ between every deploy. Secrets are compared by the ARN they are read from.
+func EnvDiff(from *ecs.ContainerDefinition, to *ecs.ContainerDefinition) []PlanChange {
+	changes := envChanges("", "env", withoutDeployEnv(from.Environment), withoutDeployEnv(to.Environment))
+	return append(changes, envChanges("", "secret", secretKeyValues(from.Secrets), secretKeyValues(to.Secrets))...)
+}
+
+func withoutDeployEnv(env []*ecs.KeyValuePair) []*ecs.KeyValuePair {
+	filtered := make([]*ecs.KeyValuePair, 0, len(env))
+	for _, kv := range env {
+		if !IsDeployEnv(aws.StringValue(kv.Name)) {
+			filtered = append(filtered, kv)
+		}
+	}
+	return filtered
+}
+
+// HasChanges reports whether a plan changes anything besides the task definition revision
+func (p *Plan) HasChanges() bool {
+	for _, change := range p.Changes() {
+		if change.Field != "revision" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// envChanges compares two container environments and returns the differences sorted by key.
+// Secrets are compared by the ARN they are read from, never by their value.
+func envChanges(container string, kind string, current []*ecs.KeyValuePair, desired []*ecs.KeyValuePair) []PlanChange {
+	changes := make([]PlanChange, 0)
+	currentEnv := keyValueMap(current)
+	desiredEnv := keyValueMap(desired)
+
+	keys := make([]string, 0)
+	for k := range currentEnv {
+		keys = append(keys, k)
+	}
+	for k := range desiredEnv {
+		if _, ok := currentEnv[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		before, inCurrent := currentEnv[k]
+		after, inDesired := desiredEnv[k]
+		field := fmt.Sprintf("%s %s", kind, k)
+
+		switch {
+		case !inDesired:
+			changes = append(changes, PlanChange{Container: container, Field: field, Action: PlanActionRemove, Before: before})
+		case !inCurrent:
+			changes = append(changes, PlanChange{Container: container, Field: field, Action: PlanActionAdd, After: after})
+		case before != after:
+			changes = append(changes, PlanChange{Container: container, Field: field, Action: PlanActionChange, Before: before, After: after})
+		}
+	}
+
+	return changes
+}
+
+func keyValueMap(keyVals []*ecs.KeyValuePair) map[string]string {
+	m := make(map[string]string, len(keyVals))
+	for _, kv := range keyVals {
+		m[aws.StringValue(kv.Name)] = aws.StringValue(kv.Value)
+	}
+	return m
+}
+
+// envFileChanges lists the environment files attached to or detached from a container
+func envFileChanges(container string, current []*ecs.EnvironmentFile, desired []*ecs.EnvironmentFile) []PlanChange {
+	changes := make([]PlanChange, 0)
+	currentFiles := envFileValues(current)
+	desiredFiles := envFileValues(desired)
+
+	for _, file := range current {
+		if !desiredFiles[aws.StringValue(file.Value)] {
+			changes = append(changes, PlanChange{Container: container, Field: "env file", Action: PlanActionRemove, Before: aws.StringValue(file.Value)})
+		}
+	}
+
+	for _, file := range desired {
+		if !currentFiles[aws.StringValue(file.Value)] {
+			changes = append(changes, PlanChange{Container: container, Field: "env file", Action: PlanActionAdd, After: aws.StringValue(file.Value)})
+		}
+	}
+
+	return changes
+}
+
+func envFileValues(files []*ecs.EnvironmentFile) map[string]bool {
+	m := make(map[string]bool, len(files))
+	for _, file := range files {
+		m[aws.StringValue(file.Value)] = true
+	}
+	return m
+}
+
+// secretKeyValues pairs the names of secrets with the ARNs they are read from
+func secretKeyValues(secrets []*ecs.Secret) []*ecs.KeyValuePair {
+	keyVals := make([]*ecs.KeyValuePair, len(secrets))
+	for i, secret := range secrets {
+		keyVals[i] = &ecs.KeyValuePair{Name: secret.Name, Value: secret.ValueFrom}
+	}
+	return keyVals
+}
+
+func containersByName(t *ecs.TaskDefinition) map[string]*ecs.ContainerDefinition {
+	m := make(map[string]*ecs.ContainerDefinition, len(t.ContainerDefinitions))
+	for _, container := range t.ContainerDefinitions {
+		m[aws.StringValue(container.Name)] = container
+	}
+	return m
+}
+
+func sortedContainerNames(a map[string]*ecs.ContainerDefinition, b map[string]*ecs.ContainerDefinition) []string {
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// familyRevision returns the family:revision part of a task definition ARN
+func familyRevision(t *ecs.TaskDefinition) string {
+	r := regexp.MustCompile(`([^\/]+)$`)
+	return r.FindString(aws.StringValue(t.TaskDefinitionArn))
+}
+
+// CopyTaskDefinition returns a copy of a task definition whose container definitions,
+// environments, secrets and environment files can be modified without changing the original
+func CopyTaskDefinition(t *ecs.TaskDefinition) *ecs.TaskDefinition {
+	taskDef := *t
+	taskDef.ContainerDefinitions = make([]*ecs.ContainerDefinition, len(t.ContainerDefinitions))
+
+	for i, container := range t.ContainerDefinitions {
+		c := *container
+		c.Environment = make([]*ecs.KeyValuePair, len(container.Environment))
+		for j, kv := range container.Environment {
+			env := *kv
+			c.Environment[j] = &env
+		}
+		c.Secrets = make([]*ecs.Secret, len(container.Secrets))
+		for j, secret := range container.Secrets {
+			sec := *secret
+			c.Secrets[j] = &sec
+		}
+		c.EnvironmentFiles = make([]*ecs.EnvironmentFile, len(container.EnvironmentFiles))
+		for j, file := range container.EnvironmentFiles {
+			f := *file
+			c.EnvironmentFiles[j] = &f
+		}
+		taskDef.ContainerDefinitions[i] = &c
+	}
+
+	return &taskDef
+}
@@ -0,0 +1,263 @@
+package outback
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// RevisionDetail summarises a task definition revision and the deploy that registered it
+type RevisionDetail struct {
+	TaskDefinitionArn string `json:"taskDefinitionArn"`
+	Revision          int64  `json:"revision"`
+	Status            string `json:"status"`
+	DeployTime        string `json:"deployTime"`
+	DeployCommit      string `json:"deployCommit"`
+	Image             string `json:"image"`
+	ImageTag          string `json:"imageTag"`
+	Current           bool   `json:"current"`
+}
+
+// GetRevisionHistory describes the newest revisions of the task definition family a service
+// runs, newest first. A limit of 0 or less returns every active revision.
+func (u *Outback) GetRevisionHistory(s *ecs.Service, limit int) ([]*RevisionDetail, error) {
+	family, _ := ParseTaskDefinitionArn(aws.StringValue(s.TaskDefinition))
+
+	revisions, err := u.TaskDefinitionRevisions(family)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(revisions) > limit {
+		revisions = revisions[:limit]
+	}
+
+	history := make([]*RevisionDetail, 0, len(revisions))
+
+	for _, arn := range revisions {
+		result, err := u.ECS.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+			TaskDefinition: arn,
+		})
+
+		if err != nil {
+			return nil, errors.Wrap(err, errCouldNotRetrieveTaskDefinition)
+		}
+
+		detail := NewRevisionDetail(result.TaskDefinition)
+		detail.Current = detail.TaskDefinitionArn == aws.StringValue(s.TaskDefinition)
+		history = append(history, detail)
+	}
+
+	return history, nil
+}
+
+// NewRevisionDetail reads the deploy markers and image of a task definition revision
+func NewRevisionDetail(t *ecs.TaskDefinition) *RevisionDetail {
+	detail := &RevisionDetail{
+		TaskDefinitionArn: aws.StringValue(t.TaskDefinitionArn),
+		Revision:          aws.Int64Value(t.Revision),
+		Status:            aws.StringValue(t.Status),
+		DeployTime:        DeployEnv(t, DEPLOY_TIME_ENV_VAR),
+		DeployCommit:      DeployEnv(t, DEPLOY_SHA_ENV_VAR),
+	}
+
+	if container := deployContainer(t); container != nil {
+		detail.Image = aws.StringValue(container.Image)
+		_, detail.ImageTag, _ = ParseImage(detail.Image)
+	}
+
+	return detail
+}
+
+// deployContainer returns the container carrying the deploy markers, or the first container
+// when the revision was not registered by a deploy
+func deployContainer(t *ecs.TaskDefinition) *ecs.ContainerDefinition {
+	for _, container := range t.ContainerDefinitions {
+		for _, env := range container.Environment {
+			if aws.StringValue(env.Name) == DEPLOY_SHA_ENV_VAR {
+				return container
+			}
+		}
+	}
+
+	if len(t.ContainerDefinitions) > 0 {
+		return t.ContainerDefinitions[0]
+	}
+
+	return nil
+}
+
+// ParseTaskDefinitionArn returns the family and revision of a task definition ARN or
+// family:revision string
+func ParseTaskDefinitionArn(arn string) (string, int) {
+	familyRevision := arn
+	if i := strings.LastIndex(arn, "/"); i >= 0 {
+		familyRevision = arn[i+1:]
+	}
+
+	split := strings.SplitN(familyRevision, ":", 2)
+	if len(split) != 2 {
+		return familyRevision, 0
+	}
+
+	revision, _ := strconv.Atoi(split[1])
+	return split[0], revision
+}
+
+// TaskDefinitionRevisions returns the ARNs of every active revision of a task definition
+// family, newest first
+func (u *Outback) TaskDefinitionRevisions(family string) ([]*string, error) {
+	revisions := make([]*string, 0)
+	suffix := fmt.Sprintf("/%s:", family)
+
+	err := u.ECS.ListTaskDefinitionsPages(&ecs.ListTaskDefinitionsInput{
+		FamilyPrefix: aws.String(family),
+		Sort:         aws.String(ecs.SortOrderDesc),
+	}, func(resp *ecs.ListTaskDefinitionsOutput, lastPage bool) bool {
+		for _, arn := range resp.TaskDefinitionArns {
+			// the family prefix also matches families that only start with the family name
+			if strings.Contains(aws.StringValue(arn), suffix) {
+				revisions = append(revisions, arn)
+			}
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, errFailedToListTaskDefinitions)
+	}
+
+	return revisions, nil
+}
+
+// FindTaskDefinitionByCommit returns the newest active revision of a task definition family
+// that was deployed with the given git commit
+func (u *Outback) FindTaskDefinitionByCommit(family string, commit string) (*ecs.TaskDefinition, error) {
+	revisions, err := u.TaskDefinitionRevisions(family)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, arn := range revisions {
+		result, err := u.ECS.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+			TaskDefinition: arn,
+		})
+
+		if err != nil {
+			return nil, errors.Wrap(err, errCouldNotRetrieveTaskDefinition)
+		}
+
+		if sameCommit(DeployEnv(result.TaskDefinition, DEPLOY_SHA_ENV_VAR), commit) {
+			return result.TaskDefinition, nil
+		}
+	}
+
+	return nil, fmt.Errorf("'%s' %s '%s'", commit, errNoRevisionForCommit, family)
+}
+
+// PreviousTaskDefinition returns the revision a service was running before its current deploy.
+// Candidates are the active revisions older than the current one, newest first, skipping
+// revisions that were not registered by a deploy, revisions that only changed the environment
+// of the current deploy and revisions of deployments the service reports as failed. Failed
+// deployments are only reported until a newer deployment settles, see failedTaskDefinitions.
+func (u *Outback) PreviousTaskDefinition(s *ecs.Service, t *ecs.TaskDefinition) (*ecs.TaskDefinition, error) {
+	family, current := ParseTaskDefinitionArn(aws.StringValue(t.TaskDefinitionArn))
+	failed := failedTaskDefinitions(s)
+
+	revisions, err := u.TaskDefinitionRevisions(family)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, arn := range revisions {
+		if _, revision := ParseTaskDefinitionArn(aws.StringValue(arn)); revision >= current {
+			continue
+		}
+
+		if failed[aws.StringValue(arn)] {
+			continue
+		}
+
+		result, err := u.ECS.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+			TaskDefinition: arn,
+		})
+
+		if err != nil {
+			return nil, errors.Wrap(err, errCouldNotRetrieveTaskDefinition)
+		}
+
+		candidate := result.TaskDefinition
+
+		if DeployEnv(candidate, DEPLOY_SHA_ENV_VAR) == "" || sameDeploy(candidate, t) {
+			continue
+		}
+
+		return candidate, nil
+	}
+
+	return nil, fmt.Errorf("'%s' %s", familyRevision(t), errNoRollbackCandidate)
+}
+
+// failedTaskDefinitions returns the task definitions of the service deployments that failed
+// to roll out or could not keep any task running. Only the deployments the service still lists
+// are known: ECS drops a failed deployment once a newer deployment has settled, after which the
+// revision of the failed deployment is no longer recognised as failed.
+func failedTaskDefinitions(s *ecs.Service) map[string]bool {
+	failed := map[string]bool{}
+
+	for _, deployment := range s.Deployments {
+		if aws.StringValue(deployment.RolloutState) == ecs.DeploymentRolloutStateFailed ||
+			(aws.Int64Value(deployment.FailedTasks) > 0 && aws.Int64Value(deployment.RunningCount) == 0) {
+			failed[aws.StringValue(deployment.TaskDefinition)] = true
+		}
+	}
+
+	return failed
+}
+
+// sameDeploy reports whether two revisions carry the deploy markers of the same deploy, which
+// is the case when one only changed the environment of the other
+func sameDeploy(a *ecs.TaskDefinition, b *ecs.TaskDefinition) bool {
+	return DeployEnv(a, DEPLOY_SHA_ENV_VAR) == DeployEnv(b, DEPLOY_SHA_ENV_VAR) &&
+		DeployEnv(a, DEPLOY_TIME_ENV_VAR) == DeployEnv(b, DEPLOY_TIME_ENV_VAR)
+}
+
+// DeployEnv returns the value of an environment variable set on any container of a task
+// definition, such as the deploy markers added by RegisterTaskDefinitionWithImage
+func DeployEnv(t *ecs.TaskDefinition, name string) string {
+	for _, container := range t.ContainerDefinitions {
+		for _, env := range container.Environment {
+			if aws.StringValue(env.Name) == name {
+				return aws.StringValue(env.Value)
+			}
+		}
+	}
+
+	return ""
+}
+
+// minCommitLength is the shortest abbreviation of a commit that is matched against deployed
+// commits, the default abbreviation length of git
+const minCommitLength = 7
+
+// sameCommit compares a deployed commit with a commit given by a user, which may be an
+// abbreviation of the deployed commit of at least minCommitLength characters
+func sameCommit(deployed string, commit string) bool {
+	if deployed == "" || commit == "" {
+		return false
+	}
+
+	if deployed == commit {
+		return true
+	}
+
+	return len(commit) >= minCommitLength && strings.HasPrefix(deployed, commit)
+}
@@ -0,0 +1,128 @@
+package outback
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// ServiceResourceID returns the Application Auto Scaling resource ID of a service
+func ServiceResourceID(c *ecs.Cluster, s *ecs.Service) string {
+	return fmt.Sprintf("service/%s/%s", aws.StringValue(c.ClusterName), aws.StringValue(s.ServiceName))
+}
+
+// GetScalableTarget returns the Application Auto Scaling target of a service's desired count,
+// or nil when the service is not registered as a scalable target
+func (u *Outback) GetScalableTarget(c *ecs.Cluster, s *ecs.Service) (*applicationautoscaling.ScalableTarget, error) {
+	res, err := u.AAS.DescribeScalableTargets(&applicationautoscaling.DescribeScalableTargetsInput{
+		ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceEcs),
+		ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionEcsServiceDesiredCount),
+		ResourceIds:       []*string{aws.String(ServiceResourceID(c, s))},
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, errCouldNotRetrieveScalableTarget)
+	}
+
+	if len(res.ScalableTargets) < 1 {
+		return nil, nil
+	}
+
+	return res.ScalableTargets[0], nil
+}
+
+// UpdateScalableTarget sets the capacity range of a service's scalable target, registering the
+// service as a scalable target when it is not one yet. A nil bound keeps the bound of the
+// existing target.
+func (u *Outback) UpdateScalableTarget(c *ecs.Cluster, s *ecs.Service, target *applicationautoscaling.ScalableTarget, min *int64, max *int64) error {
+	if target != nil {
+		if min == nil {
+			min = target.MinCapacity
+		}
+
+		if max == nil {
+			max = target.MaxCapacity
+		}
+	}
+
+	if min == nil || max == nil {
+		return errors.New(errIncompleteScalableTarget)
+	}
+
+	if *min > *max {
+		return fmt.Errorf("min %d %s %d", *min, errInvalidScalableTarget, *max)
+	}
+
+	_, err := u.AAS.RegisterScalableTarget(&applicationautoscaling.RegisterScalableTargetInput{
+		ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceEcs),
+		ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionEcsServiceDesiredCount),
+		ResourceId:        aws.String(ServiceResourceID(c, s)),
+		MinCapacity:       min,
+		MaxCapacity:       max,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, errCouldNotUpdateScalableTarget)
+	}
+
+	return nil
+}
+
+// ScaleService updates the desired count of a service. A count outside the capacity range of
+// the service's scalable target is rejected, as auto scaling would move it back into range.
+func (u *Outback) ScaleService(c *ecs.Cluster, s *ecs.Service, target *applicationautoscaling.ScalableTarget, count int64) (*ecs.Service, error) {
+	if target != nil && (count < aws.Int64Value(target.MinCapacity) || count > aws.Int64Value(target.MaxCapacity)) {
+		return nil, fmt.Errorf("%d %s %d-%d", count, errCountOutsideScalableTarget, aws.Int64Value(target.MinCapacity), aws.Int64Value(target.MaxCapacity))
+	}
+
+	result, err := u.ECS.UpdateService(&ecs.UpdateServiceInput{
+		Cluster:      c.ClusterArn,
+		Service:      s.ServiceArn,
+		DesiredCount: aws.Int64(count),
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, errCouldNotUpdateService)
+	}
+
+	return result.Service, nil
+}
+
+// AwaitServiceScaled polls a service until its running count reaches count and sends the
+// service on the returned channel after every poll, so progress can be printed. The channel is
+// closed once the service has converged.
+func (u *Outback) AwaitServiceScaled(c *ecs.Cluster, s *ecs.Service, count int64) chan *ecs.Service {
+	waitTime := time.Second * 2
+	progressCh := make(chan *ecs.Service)
+
+	go func() {
+		defer close(progressCh)
+
+		for {
+			service, err := u.GetService(c, aws.StringValue(s.ServiceName))
+
+			if err == nil {
+				progressCh <- service
+
+				if IsServiceScaled(service, count) {
+					return
+				}
+			}
+
+			time.Sleep(waitTime)
+		}
+	}()
+
+	return progressCh
+}
+
+// IsServiceScaled reports whether a service runs count tasks and has no pending tasks
+func IsServiceScaled(s *ecs.Service, count int64) bool {
+	return aws.Int64Value(s.DesiredCount) == count &&
+		aws.Int64Value(s.RunningCount) == count &&
+		aws.Int64Value(s.PendingCount) == 0
+}
@@ -0,0 +1,339 @@
+package outback
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/pkg/errors"
+)
+
+// ScheduleTargetID is the ID of the target that runs the task of a schedule rule
+const ScheduleTargetID = "outback-task"
+
+const (
+	scheduleRulePrefix     = "outback-"
+	scheduleRuleNameLength = 64
+)
+
+var invalidRuleNameChars = regexp.MustCompile(`[^.\-_A-Za-z0-9]+`)
+
+// Schedule is an EventBridge rule that runs a one-off task on a cron or rate expression
+type Schedule struct {
+	Rule        string
+	Expression  string
+	State       string
+	Description string
+	Target      *eventbridge.Target
+}
+
+// TaskDefinition returns the task definition the schedule runs
+func (s *Schedule) TaskDefinition() string {
+	if s.Target == nil || s.Target.EcsParameters == nil {
+		return ""
+	}
+
+	return aws.StringValue(s.Target.EcsParameters.TaskDefinitionArn)
+}
+
+// scheduleInput is the JSON input of a schedule target, which EventBridge passes to ECS as
+// the overrides of the task it runs
+type scheduleInput struct {
+	ContainerOverrides []scheduleContainerOverride `json:"containerOverrides"`
+	Cpu                string                      `json:"cpu,omitempty"`
+	Memory             string                      `json:"memory,omitempty"`
+}
+
+type scheduleContainerOverride struct {
+	Name        string             `json:"name"`
+	Command     []string           `json:"command,omitempty"`
+	Environment []scheduleKeyValue `json:"environment,omitempty"`
+}
+
+type scheduleKeyValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ScheduleRuleName returns the name of the rule that runs a task alias on a cluster. Characters
+// EventBridge does not allow in rule names are replaced with dashes.
+func ScheduleRuleName(cluster string, task string) string {
+	name := scheduleRulePrefix + invalidRuleNameChars.ReplaceAllString(cluster, "-") + "-" + invalidRuleNameChars.ReplaceAllString(task, "-")
+
+	if len(name) > scheduleRuleNameLength {
+		return name[:scheduleRuleNameLength]
+	}
+
+	return name
+}
+
+// GetSchedule returns a schedule rule with its task target, or nil when the rule does not exist
+func (u *Outback) GetSchedule(rule string) (*Schedule, error) {
+	res, err := u.EB.DescribeRule(&eventbridge.DescribeRuleInput{
+		Name: aws.String(rule),
+	})
+
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == eventbridge.ErrCodeResourceNotFoundException {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, errCouldNotRetrieveSchedule)
+	}
+
+	target, err := u.scheduleTarget(rule)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Schedule{
+		Rule:        aws.StringValue(res.Name),
+		Expression:  aws.StringValue(res.ScheduleExpression),
+		State:       aws.StringValue(res.State),
+		Description: aws.StringValue(res.Description),
+		Target:      target,
+	}, nil
+}
+
+// ListSchedules returns the schedule rules created by outback that run tasks on a cluster
+func (u *Outback) ListSchedules(c *ecs.Cluster) ([]*Schedule, error) {
+	schedules := make([]*Schedule, 0)
+	in := &eventbridge.ListRulesInput{NamePrefix: aws.String(scheduleRulePrefix)}
+
+	for {
+		res, err := u.EB.ListRules(in)
+
+		if err != nil {
+			return nil, errors.Wrap(err, errFailedToListSchedules)
+		}
+
+		for _, rule := range res.Rules {
+			target, err := u.scheduleTarget(aws.StringValue(rule.Name))
+
+			if err != nil {
+				return nil, err
+			}
+
+			// rules of clusters whose name starts with the name of this cluster share the prefix
+			if target == nil || aws.StringValue(target.Arn) != aws.StringValue(c.ClusterArn) {
+				continue
+			}
+
+			schedules = append(schedules, &Schedule{
+				Rule:        aws.StringValue(rule.Name),
+				Expression:  aws.StringValue(rule.ScheduleExpression),
+				State:       aws.StringValue(rule.State),
+				Description: aws.StringValue(rule.Description),
+				Target:      target,
+			})
+		}
+
+		if res.NextToken == nil {
+			return schedules, nil
+		}
+
+		in.NextToken = res.NextToken
+	}
+}
+
+// scheduleTarget returns the task target of a rule, or nil when the rule has none
+func (u *Outback) scheduleTarget(rule string) (*eventbridge.Target, error) {
+	res, err := u.EB.ListTargetsByRule(&eventbridge.ListTargetsByRuleInput{
+		Rule: aws.String(rule),
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, errCouldNotRetrieveSchedule)
+	}
+
+	for _, target := range res.Targets {
+		if aws.StringValue(target.Id) == ScheduleTargetID {
+			return target, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ScheduleTarget builds the target of a schedule rule that runs a task definition on a cluster
+// with the overrides and launch settings of a one-off task. EventBridge assumes the role to
+// run the task, so the role must be allowed to run it and pass its roles.
+func (u *Outback) ScheduleTarget(c *ecs.Cluster, t *ecs.TaskDefinition, overrides TaskOverrides, launch *TaskLaunch, role string) (*eventbridge.Target, error) {
+	override, err := overrides.taskOverride(t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := json.Marshal(newScheduleInput(override))
+
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := launch.ecsParameters(t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventbridge.Target{
+		Id:            aws.String(ScheduleTargetID),
+		Arn:           c.ClusterArn,
+		RoleArn:       aws.String(role),
+		EcsParameters: params,
+		Input:         aws.String(string(input)),
+	}, nil
+}
+
+// PutSchedule creates or updates a schedule rule and its task target. EventBridge enables a
+// rule that is put without a state, so the state of an existing rule must be passed to keep a
+// disabled rule disabled. An empty state enables the rule.
+func (u *Outback) PutSchedule(rule string, expression string, state string, description string, target *eventbridge.Target) error {
+	in := &eventbridge.PutRuleInput{
+		Name:               aws.String(rule),
+		ScheduleExpression: aws.String(expression),
+		Description:        aws.String(description),
+	}
+
+	if state != "" {
+		in.State = aws.String(state)
+	}
+
+	_, err := u.EB.PutRule(in)
+
+	if err != nil {
+		return errors.Wrap(err, errCouldNotPutSchedule)
+	}
+
+	return u.putScheduleTarget(rule, target)
+}
+
+// RepointSchedule makes a schedule run another task definition, keeping the overrides and
+// launch settings of its target
+func (u *Outback) RepointSchedule(s *Schedule, t *ecs.TaskDefinition) error {
+	if s.Target == nil || s.Target.EcsParameters == nil {
+		return fmt.Errorf("'%s' %s", s.Rule, errScheduleHasNoTarget)
+	}
+
+	target := *s.Target
+	params := *s.Target.EcsParameters
+	params.TaskDefinitionArn = t.TaskDefinitionArn
+	target.EcsParameters = &params
+
+	if err := u.putScheduleTarget(s.Rule, &target); err != nil {
+		return err
+	}
+
+	s.Target = &target
+
+	return nil
+}
+
+func (u *Outback) putScheduleTarget(rule string, target *eventbridge.Target) error {
+	res, err := u.EB.PutTargets(&eventbridge.PutTargetsInput{
+		Rule:    aws.String(rule),
+		Targets: []*eventbridge.Target{target},
+	})
+
+	if err != nil {
+		return errors.Wrap(err, errCouldNotPutSchedule)
+	}
+
+	if len(res.FailedEntries) > 0 {
+		return fmt.Errorf("'%s' %s: %s", rule, errScheduleTargetRejected, aws.StringValue(res.FailedEntries[0].ErrorMessage))
+	}
+
+	return nil
+}
+
+// DeleteSchedule removes the task target of a schedule rule and deletes the rule
+func (u *Outback) DeleteSchedule(rule string) error {
+	_, err := u.EB.RemoveTargets(&eventbridge.RemoveTargetsInput{
+		Rule: aws.String(rule),
+		Ids:  aws.StringSlice([]string{ScheduleTargetID}),
+	})
+
+	if err != nil {
+		return errors.Wrap(err, errCouldNotDeleteSchedule)
+	}
+
+	_, err = u.EB.DeleteRule(&eventbridge.DeleteRuleInput{
+		Name: aws.String(rule),
+	})
+
+	if err != nil {
+		return errors.Wrap(err, errCouldNotDeleteSchedule)
+	}
+
+	return nil
+}
+
+// newScheduleInput converts run task overrides to the input of a schedule target
+func newScheduleInput(o *ecs.TaskOverride) scheduleInput {
+	input := scheduleInput{
+		Cpu:    aws.StringValue(o.Cpu),
+		Memory: aws.StringValue(o.Memory),
+	}
+
+	for _, container := range o.ContainerOverrides {
+		override := scheduleContainerOverride{
+			Name:    aws.StringValue(container.Name),
+			Command: aws.StringValueSlice(container.Command),
+		}
+
+		for _, kv := range container.Environment {
+			override.Environment = append(override.Environment, scheduleKeyValue{
+				Name:  aws.StringValue(kv.Name),
+				Value: aws.StringValue(kv.Value),
+			})
+		}
+
+		input.ContainerOverrides = append(input.ContainerOverrides, override)
+	}
+
+	return input
+}
+
+// ecsParameters converts the launch settings of a one-off task to the ECS parameters of a
+// schedule target running the task definition
+func (l *TaskLaunch) ecsParameters(t *ecs.TaskDefinition) (*eventbridge.EcsParameters, error) {
+	in := &ecs.RunTaskInput{}
+
+	if err := l.apply(in, t); err != nil {
+		return nil, err
+	}
+
+	params := &eventbridge.EcsParameters{
+		TaskDefinitionArn: t.TaskDefinitionArn,
+		TaskCount:         aws.Int64(1),
+		LaunchType:        in.LaunchType,
+		PlatformVersion:   in.PlatformVersion,
+	}
+
+	for _, item := range in.CapacityProviderStrategy {
+		params.CapacityProviderStrategy = append(params.CapacityProviderStrategy, &eventbridge.CapacityProviderStrategyItem{
+			CapacityProvider: item.CapacityProvider,
+			Weight:           item.Weight,
+			Base:             item.Base,
+		})
+	}
+
+	if in.NetworkConfiguration != nil {
+		vpc := in.NetworkConfiguration.AwsvpcConfiguration
+		params.NetworkConfiguration = &eventbridge.NetworkConfiguration{
+			AwsvpcConfiguration: &eventbridge.AwsVpcConfiguration{
+				Subnets:        vpc.Subnets,
+				SecurityGroups: vpc.SecurityGroups,
+				AssignPublicIp: vpc.AssignPublicIp,
+			},
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,216 @@
+package outback
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/pkg/errors"
+)
+
+const (
+	SecretStoreSSM            = "ssm"
+	SecretStoreSecretsManager = "secretsmanager"
+)
+
+// SecretName returns the name a secret of a service is stored under. SSM parameters are
+// stored in the /outback/cluster/service/ hierarchy, Secrets Manager secrets under the
+// outback/cluster/service/ prefix.
+func SecretName(store string, cluster string, service string, key string) string {
+	name := fmt.Sprintf("outback/%s/%s/%s", cluster, service, key)
+
+	if store == SecretStoreSSM {
+		return "/" + name
+	}
+
+	return name
+}
+
+// PutSecret creates or overwrites a secret in the given store and returns the ARN a container
+// definition can reference in the valueFrom of its secrets
+func (u *Outback) PutSecret(store string, name string, value string) (string, error) {
+	switch store {
+	case SecretStoreSSM:
+		return u.putParameter(name, value)
+	case SecretStoreSecretsManager:
+		return u.putSecretValue(name, value)
+	}
+
+	return "", fmt.Errorf("'%s' %s", store, errInvalidSecretStore)
+}
+
+func (u *Outback) putParameter(name string, value string) (string, error) {
+	_, err := u.SSM.PutParameter(&ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     aws.String(value),
+		Type:      aws.String(ssm.ParameterTypeSecureString),
+		Overwrite: aws.Bool(true),
+	})
+
+	if err != nil {
+		return "", errors.Wrap(err, errCouldNotPutSecret)
+	}
+
+	result, err := u.SSM.GetParameter(&ssm.GetParameterInput{
+		Name: aws.String(name),
+	})
+
+	if err != nil {
+		return "", errors.Wrap(err, errCouldNotPutSecret)
+	}
+
+	return aws.StringValue(result.Parameter.ARN), nil
+}
+
+func (u *Outback) putSecretValue(name string, value string) (string, error) {
+	created, err := u.SM.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String(name),
+		SecretString: aws.String(value),
+	})
+
+	if err == nil {
+		return aws.StringValue(created.ARN), nil
+	}
+
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != secretsmanager.ErrCodeResourceExistsException {
+		return "", errors.Wrap(err, errCouldNotPutSecret)
+	}
+
+	updated, err := u.SM.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name),
+		SecretString: aws.String(value),
+	})
+
+	if err != nil {
+		return "", errors.Wrap(err, errCouldNotPutSecret)
+	}
+
+	return aws.StringValue(updated.ARN), nil
+}
+
+// DeleteSecret deletes the SSM parameter or Secrets Manager secret a valueFrom ARN points to.
+// Secrets Manager secrets are scheduled for deletion with the default recovery window.
+func (u *Outback) DeleteSecret(valueFrom string) error {
+	var err error
+
+	switch SecretStore(valueFrom) {
+	case SecretStoreSSM:
+		_, err = u.SSM.DeleteParameter(&ssm.DeleteParameterInput{
+			Name: aws.String(ParameterName(valueFrom)),
+		})
+	case SecretStoreSecretsManager:
+		_, err = u.SM.DeleteSecret(&secretsmanager.DeleteSecretInput{
+			SecretId: aws.String(valueFrom),
+		})
+	default:
+		return fmt.Errorf("'%s' %s", valueFrom, errInvalidSecretStore)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, errCouldNotDeleteSecret)
+	}
+
+	return nil
+}
+
+// SecretStore returns the store a valueFrom ARN belongs to. Plain parameter names, which ECS
+// accepts for parameters in the same region, belong to SSM.
+func SecretStore(valueFrom string) string {
+	switch {
+	case strings.HasPrefix(valueFrom, "arn:") && strings.Contains(valueFrom, ":secretsmanager:"):
+		return SecretStoreSecretsManager
+	case strings.HasPrefix(valueFrom, "arn:") && strings.Contains(valueFrom, ":ssm:"):
+		return SecretStoreSSM
+	case !strings.HasPrefix(valueFrom, "arn:"):
+		return SecretStoreSSM
+	}
+
+	return ""
+}
+
+// ParameterName returns the name of the SSM parameter a valueFrom ARN or name refers to
+func ParameterName(valueFrom string) string {
+	if !strings.HasPrefix(valueFrom, "arn:") {
+		return valueFrom
+	}
+
+	name := valueFrom[strings.Index(valueFrom, ":parameter")+len(":parameter"):]
+
+	// parameters outside a hierarchy have no leading slash in their name
+	if strings.Count(name, "/") == 1 {
+		return strings.TrimPrefix(name, "/")
+	}
+
+	return name
+}
+
+// UpdateContainerDefinitionSecrets adds or replaces the secrets of the container selected by
+// ContainerIndex
+func (u *Outback) UpdateContainerDefinitionSecrets(t ecs.TaskDefinition, updates []*ecs.Secret, container string, repo string) (ecs.TaskDefinition, error) {
+	t = *CopyTaskDefinition(&t)
+
+	i, err := ContainerIndex(&t, container, repo)
+
+	if err != nil {
+		return t, err
+	}
+
+	currentSecrets := t.ContainerDefinitions[i].Secrets
+
+	for _, secret := range updates {
+		if j, ok := containsSecret(currentSecrets, secret); ok {
+			currentSecrets[*j].ValueFrom = secret.ValueFrom
+		} else {
+			currentSecrets = append(currentSecrets, secret)
+		}
+	}
+
+	t.ContainerDefinitions[i].Secrets = currentSecrets
+
+	return t, nil
+}
+
+// RemoveContainerDefinitionSecrets removes secrets by name from the container selected by
+// ContainerIndex and returns the removed secrets
+func (u *Outback) RemoveContainerDefinitionSecrets(t ecs.TaskDefinition, names []string, container string, repo string) (ecs.TaskDefinition, []*ecs.Secret, error) {
+	t = *CopyTaskDefinition(&t)
+
+	i, err := ContainerIndex(&t, container, repo)
+
+	if err != nil {
+		return t, nil, err
+	}
+
+	remove := map[string]bool{}
+	for _, name := range names {
+		remove[name] = true
+	}
+
+	removed := make([]*ecs.Secret, 0)
+	kept := make([]*ecs.Secret, 0, len(t.ContainerDefinitions[i].Secrets))
+
+	for _, secret := range t.ContainerDefinitions[i].Secrets {
+		if remove[aws.StringValue(secret.Name)] {
+			removed = append(removed, secret)
+		} else {
+			kept = append(kept, secret)
+		}
+	}
+
+	t.ContainerDefinitions[i].Secrets = kept
+
+	return t, removed, nil
+}
+
+func containsSecret(secrets []*ecs.Secret, secret *ecs.Secret) (*int, bool) {
+	for i, s := range secrets {
+		if aws.StringValue(s.Name) == aws.StringValue(secret.Name) {
+			return &i, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,234 @@
+package outback
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// describeTasksLimit is the most tasks DescribeTasks describes in one call
+const describeTasksLimit = 100
+
+// ServiceDetail summarises the state of a service: its deployments, recent events, load
+// balancer target groups and the tasks it is running or has recently stopped
+type ServiceDetail struct {
+	ServiceName    string                `json:"serviceName"`
+	Status         string                `json:"status"`
+	TaskDefinition *RevisionDetail       `json:"taskDefinition"`
+	Containers     []*ContainerDetail    `json:"containers"`
+	DesiredCount   int64                 `json:"desiredCount"`
+	RunningCount   int64                 `json:"runningCount"`
+	PendingCount   int64                 `json:"pendingCount"`
+	Deployments    []*DeploymentDetail   `json:"deployments"`
+	LoadBalancers  []*LoadBalancerDetail `json:"loadBalancers"`
+	Tasks          []*TaskDetail         `json:"tasks"`
+	Events         []*EventDetail        `json:"events"`
+}
+
+// ContainerDetail is a container of the task definition a service runs and its image
+type ContainerDetail struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// DeploymentDetail summarises a deployment of a service
+type DeploymentDetail struct {
+	ID                 string `json:"id"`
+	Status             string `json:"status"`
+	RolloutState       string `json:"rolloutState"`
+	RolloutStateReason string `json:"rolloutStateReason"`
+	TaskDefinitionArn  string `json:"taskDefinitionArn"`
+	DesiredCount       int64  `json:"desiredCount"`
+	PendingCount       int64  `json:"pendingCount"`
+	RunningCount       int64  `json:"runningCount"`
+	CreatedAt          string `json:"createdAt"`
+	UpdatedAt          string `json:"updatedAt"`
+}
+
+// LoadBalancerDetail is a target group or classic load balancer a container of a service is
+// registered with
+type LoadBalancerDetail struct {
+	TargetGroupArn   string `json:"targetGroupArn,omitempty"`
+	LoadBalancerName string `json:"loadBalancerName,omitempty"`
+	ContainerName    string `json:"containerName"`
+	ContainerPort    int64  `json:"containerPort"`
+}
+
+// TaskDetail summarises a task of a service
+type TaskDetail struct {
+	TaskArn           string `json:"taskArn"`
+	TaskDefinitionArn string `json:"taskDefinitionArn"`
+	LastStatus        string `json:"lastStatus"`
+	DesiredStatus     string `json:"desiredStatus"`
+	HealthStatus      string `json:"healthStatus"`
+	StartedAt         string `json:"startedAt"`
+	StoppedAt         string `json:"stoppedAt"`
+	StoppedReason     string `json:"stoppedReason"`
+}
+
+// EventDetail is a service event message
+type EventDetail struct {
+	CreatedAt string `json:"createdAt"`
+	Message   string `json:"message"`
+}
+
+// GetServiceDetail describes a service with its task definition, deployments, load balancers,
+// running and recently stopped tasks and its newest events, of which at most events are
+// returned
+func (u *Outback) GetServiceDetail(c *ecs.Cluster, s *ecs.Service, events int) (*ServiceDetail, error) {
+	t, err := u.GetTaskDefinition(c, s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := u.serviceTasks(c, s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &ServiceDetail{
+		ServiceName:    aws.StringValue(s.ServiceName),
+		Status:         aws.StringValue(s.Status),
+		TaskDefinition: NewRevisionDetail(t),
+		Containers:     make([]*ContainerDetail, 0, len(t.ContainerDefinitions)),
+		DesiredCount:   aws.Int64Value(s.DesiredCount),
+		RunningCount:   aws.Int64Value(s.RunningCount),
+		PendingCount:   aws.Int64Value(s.PendingCount),
+		Deployments:    make([]*DeploymentDetail, 0, len(s.Deployments)),
+		LoadBalancers:  make([]*LoadBalancerDetail, 0, len(s.LoadBalancers)),
+		Tasks:          make([]*TaskDetail, 0, len(tasks)),
+		Events:         make([]*EventDetail, 0),
+	}
+	detail.TaskDefinition.Current = true
+
+	for _, container := range t.ContainerDefinitions {
+		detail.Containers = append(detail.Containers, &ContainerDetail{
+			Name:  aws.StringValue(container.Name),
+			Image: aws.StringValue(container.Image),
+		})
+	}
+
+	for _, d := range s.Deployments {
+		detail.Deployments = append(detail.Deployments, &DeploymentDetail{
+			ID:                 aws.StringValue(d.Id),
+			Status:             aws.StringValue(d.Status),
+			RolloutState:       aws.StringValue(d.RolloutState),
+			RolloutStateReason: aws.StringValue(d.RolloutStateReason),
+			TaskDefinitionArn:  aws.StringValue(d.TaskDefinition),
+			DesiredCount:       aws.Int64Value(d.DesiredCount),
+			PendingCount:       aws.Int64Value(d.PendingCount),
+			RunningCount:       aws.Int64Value(d.RunningCount),
+			CreatedAt:          formatTime(d.CreatedAt),
+			UpdatedAt:          formatTime(d.UpdatedAt),
+		})
+	}
+
+	for _, lb := range s.LoadBalancers {
+		detail.LoadBalancers = append(detail.LoadBalancers, &LoadBalancerDetail{
+			TargetGroupArn:   aws.StringValue(lb.TargetGroupArn),
+			LoadBalancerName: aws.StringValue(lb.LoadBalancerName),
+			ContainerName:    aws.StringValue(lb.ContainerName),
+			ContainerPort:    aws.Int64Value(lb.ContainerPort),
+		})
+	}
+
+	for _, task := range tasks {
+		detail.Tasks = append(detail.Tasks, &TaskDetail{
+			TaskArn:           aws.StringValue(task.TaskArn),
+			TaskDefinitionArn: aws.StringValue(task.TaskDefinitionArn),
+			LastStatus:        aws.StringValue(task.LastStatus),
+			DesiredStatus:     aws.StringValue(task.DesiredStatus),
+			HealthStatus:      aws.StringValue(task.HealthStatus),
+			StartedAt:         formatTime(task.StartedAt),
+			StoppedAt:         formatTime(task.StoppedAt),
+			StoppedReason:     aws.StringValue(task.StoppedReason),
+		})
+	}
+
+	// ECS returns events newest first
+	for i, event := range s.Events {
+		if i >= events {
+			break
+		}
+
+		detail.Events = append(detail.Events, &EventDetail{
+			CreatedAt: formatTime(event.CreatedAt),
+			Message:   aws.StringValue(event.Message),
+		})
+	}
+
+	return detail, nil
+}
+
+// serviceTasks describes the running tasks of a service followed by the tasks it stopped
+// recently, which ECS keeps for about an hour
+func (u *Outback) serviceTasks(c *ecs.Cluster, s *ecs.Service) ([]*ecs.Task, error) {
+	tasks := make([]*ecs.Task, 0)
+
+	for _, status := range []string{ecs.DesiredStatusRunning, ecs.DesiredStatusStopped} {
+		arns, err := u.listServiceTasks(c, s, status)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// DescribeTasks describes at most describeTasksLimit tasks at a time and rejects an
+		// empty list of tasks
+		for start := 0; start < len(arns); start += describeTasksLimit {
+			end := start + describeTasksLimit
+			if end > len(arns) {
+				end = len(arns)
+			}
+
+			described, err := u.GetTasks(c, arns[start:end])
+
+			if err != nil {
+				return nil, err
+			}
+
+			tasks = append(tasks, described...)
+		}
+	}
+
+	return tasks, nil
+}
+
+// listServiceTasks returns the ARNs of the tasks of a service with a desired status, following
+// every page of the list
+func (u *Outback) listServiceTasks(c *ecs.Cluster, s *ecs.Service, status string) ([]*string, error) {
+	arns := make([]*string, 0)
+	in := &ecs.ListTasksInput{
+		Cluster:       c.ClusterName,
+		ServiceName:   s.ServiceName,
+		DesiredStatus: aws.String(status),
+	}
+
+	for {
+		result, err := u.ECS.ListTasks(in)
+
+		if err != nil {
+			return nil, errors.Wrap(err, errFailedToListTasks)
+		}
+
+		arns = append(arns, result.TaskArns...)
+
+		if result.NextToken == nil {
+			return arns, nil
+		}
+
+		in.NextToken = result.NextToken
+	}
+}
+
+// formatTime formats a time as RFC 3339, or returns an empty string for a nil time
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
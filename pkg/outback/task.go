@@ -0,0 +1,330 @@
+package outback
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// TaskOverrides change how the container of a one-off task runs. Command and Entrypoint are
+// parsed with SplitCommand, Cpu and Memory override the task size. ECS cannot override the
+// entrypoint of a container when running a task, see RunTaskDefinition.
+type TaskOverrides struct {
+	Container  string
+	Command    string
+	Entrypoint string
+	Env        []*ecs.KeyValuePair
+	Cpu        string
+	Memory     string
+}
+
+// TaskContainerIndex returns the index of the container a one-off task overrides: the
+// container with the given name, or the first container when no name is given
+func TaskContainerIndex(t *ecs.TaskDefinition, container string) (int, error) {
+	if container == "" {
+		if len(t.ContainerDefinitions) == 0 {
+			return 0, errors.New(errInvalidTaskDefinition)
+		}
+
+		return 0, nil
+	}
+
+	return ContainerIndex(t, container, "")
+}
+
+// RunTaskDefinition returns the task definition a one-off task runs. When the overrides replace
+// the entrypoint a copy of the task definition with that entrypoint is registered in the
+// <family>-run family, so the copy never becomes a revision the service could be rolled back to.
+func (u *Outback) RunTaskDefinition(t *ecs.TaskDefinition, overrides TaskOverrides) (*ecs.TaskDefinition, error) {
+	if overrides.Entrypoint == "" {
+		return t, nil
+	}
+
+	i, err := TaskContainerIndex(t, overrides.Container)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entrypoint, err := SplitCommand(overrides.Entrypoint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	run := CopyTaskDefinition(t)
+	run.Family = aws.String(RunTaskFamily(aws.StringValue(t.Family)))
+	run.ContainerDefinitions[i].EntryPoint = aws.StringSlice(entrypoint)
+
+	return u.RegisterTaskDefinitionWithEnvVars(run)
+}
+
+// ReleaseRunTaskDefinition deregisters a revision RunTaskDefinition registered in the run
+// family of t once no task or schedule needs it anymore, so one-off revisions do not pile up.
+// Revisions of other families, like the service's own, are left alone.
+func (u *Outback) ReleaseRunTaskDefinition(t *ecs.TaskDefinition, arn string) error {
+	if family, _ := ParseTaskDefinitionArn(arn); family != RunTaskFamily(aws.StringValue(t.Family)) {
+		return nil
+	}
+
+	return u.DeregisterTaskDefinition(&ecs.TaskDefinition{TaskDefinitionArn: aws.String(arn)})
+}
+
+// RunTaskFamily returns the family one-off task definitions of a service family are
+// registered in
+func RunTaskFamily(family string) string {
+	return family + "-run"
+}
+
+// taskOverride builds the run task overrides of a task definition
+func (o TaskOverrides) taskOverride(t *ecs.TaskDefinition) (*ecs.TaskOverride, error) {
+	i, err := TaskContainerIndex(t, o.Container)
+
+	if err != nil {
+		return nil, err
+	}
+
+	container := &ecs.ContainerOverride{Name: t.ContainerDefinitions[i].Name}
+
+	if o.Command != "" {
+		command, err := SplitCommand(o.Command)
+
+		if err != nil {
+			return nil, err
+		}
+
+		container.Command = aws.StringSlice(command)
+	}
+
+	if len(o.Env) > 0 {
+		container.Environment = o.Env
+	}
+
+	override := &ecs.TaskOverride{ContainerOverrides: []*ecs.ContainerOverride{container}}
+
+	if o.Cpu != "" {
+		override.Cpu = aws.String(o.Cpu)
+	}
+
+	if o.Memory != "" {
+		override.Memory = aws.String(o.Memory)
+	}
+
+	return override, nil
+}
+
+// SplitCommand splits a command into its arguments following the quoting rules of a POSIX
+// shell: arguments are separated by whitespace, single quotes keep everything up to the next
+// single quote, double quotes keep everything but backslash escapes of ", \, $ and ` and a
+// backslash outside of quotes escapes the next character. Variables and globs are not expanded.
+func SplitCommand(command string) ([]string, error) {
+	args := make([]string, 0)
+	var arg strings.Builder
+	inArg := false
+	runes := []rune(command)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("'%s' %s", command, errUnterminatedEscape)
+			}
+
+			i++
+			arg.WriteRune(runes[i])
+			inArg = true
+		case r == '\'':
+			end := strings.IndexRune(string(runes[i+1:]), '\'')
+
+			if end < 0 {
+				return nil, fmt.Errorf("'%s' %s", command, errUnterminatedQuote)
+			}
+
+			quoted := []rune(string(runes[i+1:])[:end])
+			arg.WriteString(string(quoted))
+			i += len(quoted) + 1
+			inArg = true
+		case r == '"':
+			closed := false
+
+			for i++; i < len(runes); i++ {
+				if runes[i] == '"' {
+					closed = true
+					break
+				}
+
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+
+				arg.WriteRune(runes[i])
+			}
+
+			if !closed {
+				return nil, fmt.Errorf("'%s' %s", command, errUnterminatedQuote)
+			}
+
+			inArg = true
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
+
+// TaskFailure returns an error when a stopped task failed: when an essential container exited
+// with a non-zero code, or stopped without an exit code because it never ran
+func TaskFailure(task *ecs.Task, t *ecs.TaskDefinition) error {
+	essential := map[string]bool{}
+	for _, container := range t.ContainerDefinitions {
+		// containers are essential unless marked otherwise
+		essential[aws.StringValue(container.Name)] = container.Essential == nil || *container.Essential
+	}
+
+	for _, container := range task.Containers {
+		name := aws.StringValue(container.Name)
+
+		if !essential[name] {
+			continue
+		}
+
+		if container.ExitCode == nil {
+			return fmt.Errorf("'%s' %s: %s", name, errContainerDidNotRun, aws.StringValue(task.StoppedReason))
+		}
+
+		if *container.ExitCode != 0 {
+			return fmt.Errorf("'%s' %s %d", name, errContainerExitCode, *container.ExitCode)
+		}
+	}
+
+	return nil
+}
+
+// TaskLaunch is how a one-off task is placed and networked: either a launch type or a
+// capacity provider strategy, the Fargate platform version and the awsvpc configuration
+type TaskLaunch struct {
+	LaunchType               string
+	CapacityProviderStrategy []*ecs.CapacityProviderStrategyItem
+	PlatformVersion          string
+	Subnets                  []string
+	SecurityGroups           []string
+	AssignPublicIP           string
+}
+
+// ServiceTaskLaunch returns the launch settings of a service, so tasks based on the service
+// run with the same networking
+func ServiceTaskLaunch(s *ecs.Service) *TaskLaunch {
+	launch := &TaskLaunch{
+		LaunchType:               aws.StringValue(s.LaunchType),
+		CapacityProviderStrategy: s.CapacityProviderStrategy,
+		PlatformVersion:          aws.StringValue(s.PlatformVersion),
+	}
+
+	if s.NetworkConfiguration != nil && s.NetworkConfiguration.AwsvpcConfiguration != nil {
+		vpc := s.NetworkConfiguration.AwsvpcConfiguration
+		launch.Subnets = aws.StringValueSlice(vpc.Subnets)
+		launch.SecurityGroups = aws.StringValueSlice(vpc.SecurityGroups)
+		launch.AssignPublicIP = aws.StringValue(vpc.AssignPublicIp)
+	}
+
+	return launch
+}
+
+// SetLaunchType runs the task with a launch type instead of a capacity provider strategy
+func (l *TaskLaunch) SetLaunchType(launchType string) {
+	l.LaunchType = strings.ToUpper(launchType)
+	l.CapacityProviderStrategy = nil
+}
+
+// SetCapacityProviders runs the task with a capacity provider strategy instead of a launch
+// type. Providers are given as name, name:weight or name:weight:base.
+func (l *TaskLaunch) SetCapacityProviders(providers []string) error {
+	strategy := make([]*ecs.CapacityProviderStrategyItem, 0, len(providers))
+
+	for _, provider := range providers {
+		split := strings.Split(provider, ":")
+
+		if len(split) > 3 || split[0] == "" {
+			return fmt.Errorf("'%s' %s", provider, errInvalidCapacityProvider)
+		}
+
+		item := &ecs.CapacityProviderStrategyItem{CapacityProvider: aws.String(split[0])}
+
+		for i, field := range []**int64{&item.Weight, &item.Base} {
+			if len(split) <= i+1 {
+				break
+			}
+
+			n, err := strconv.ParseInt(split[i+1], 10, 64)
+
+			if err != nil || n < 0 {
+				return fmt.Errorf("'%s' %s", provider, errInvalidCapacityProvider)
+			}
+
+			*field = aws.Int64(n)
+		}
+
+		strategy = append(strategy, item)
+	}
+
+	l.LaunchType = ""
+	l.CapacityProviderStrategy = strategy
+
+	return nil
+}
+
+// apply sets the launch settings of a run task request. The network configuration is only set
+// for task definitions using the awsvpc network mode, which require at least one subnet.
+func (l *TaskLaunch) apply(in *ecs.RunTaskInput, t *ecs.TaskDefinition) error {
+	if l.LaunchType != "" {
+		in.LaunchType = aws.String(l.LaunchType)
+	} else if len(l.CapacityProviderStrategy) > 0 {
+		in.CapacityProviderStrategy = l.CapacityProviderStrategy
+	}
+
+	if l.PlatformVersion != "" {
+		in.PlatformVersion = aws.String(l.PlatformVersion)
+	}
+
+	if aws.StringValue(t.NetworkMode) != ecs.NetworkModeAwsvpc {
+		return nil
+	}
+
+	if len(l.Subnets) == 0 {
+		return fmt.Errorf("'%s' %s", aws.StringValue(t.Family), errNoTaskSubnets)
+	}
+
+	vpc := &ecs.AwsVpcConfiguration{
+		Subnets: aws.StringSlice(l.Subnets),
+	}
+
+	if len(l.SecurityGroups) > 0 {
+		vpc.SecurityGroups = aws.StringSlice(l.SecurityGroups)
+	}
+
+	if l.AssignPublicIP != "" {
+		vpc.AssignPublicIp = aws.String(strings.ToUpper(l.AssignPublicIP))
+	}
+
+	in.NetworkConfiguration = &ecs.NetworkConfiguration{AwsvpcConfiguration: vpc}
+
+	return nil
+}